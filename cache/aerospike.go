@@ -3,23 +3,27 @@ package cache
 import (
 	"encoding/json"
 	"errors"
-	"os"
 	"strconv"
 	"strings"
 
+	"restapi/config"
+
 	as "github.com/aerospike/aerospike-client-go"
 )
 
 type Aerospike struct {
 	client    *as.Client
 	namespace string
+	enabled   bool
 }
 
-func NewAerospikeCache() *Aerospike {
-	instance := &Aerospike{}
-	if os.Getenv("CACHE") == "true" {
+// NewAerospikeCacheFromConfig connects to the hosts of cfg. When the cache
+// is disabled every call is a no-op.
+func NewAerospikeCacheFromConfig(cfg config.Cache) *Aerospike {
+	instance := &Aerospike{enabled: cfg.Enabled}
+	if cfg.Enabled {
 
-		asHosts := getHosts()
+		asHosts := cfg.Hosts
 		hosts := []*as.Host{}
 		for _, host := range asHosts {
 			hostAndPort := strings.Split(host, ":")
@@ -34,7 +38,7 @@ func NewAerospikeCache() *Aerospike {
 		if err != nil {
 			return instance
 		}
-		instance.namespace = os.Getenv("AEROSPIKE_NAMESPACE")
+		instance.namespace = cfg.Namespace
 		instance.client = client
 	}
 
@@ -43,7 +47,7 @@ func NewAerospikeCache() *Aerospike {
 
 func (cache *Aerospike) SetJson(set string, key string, data interface{}, expiration int) error {
 
-	if os.Getenv("CACHE") != "true" {
+	if !cache.enabled {
 		return nil
 	}
 
@@ -68,13 +72,18 @@ func (cache *Aerospike) SetJson(set string, key string, data interface{}, expira
 	var writePolicy = as.NewWritePolicy(0, 0)
 	writePolicy.Expiration = uint32(expiration)
 
-	return cache.client.PutBins(writePolicy, asKey, binVal)
+	err = cache.client.PutBins(writePolicy, asKey, binVal)
+	if err != nil {
+		cacheErrors.WithLabelValues(set, "set").Inc()
+	}
+
+	return err
 
 }
 
 func (cache *Aerospike) GetJson(set string, key string, container interface{}) (interface{}, error) {
 
-	if os.Getenv("CACHE") != "true" {
+	if !cache.enabled {
 		return nil, nil
 	}
 
@@ -88,26 +97,71 @@ func (cache *Aerospike) GetJson(set string, key string, container interface{}) (
 	}
 	data, err := cache.client.Get(nil, asKey, "val")
 	if err != nil {
+		if isKeyNotFound(err) {
+			cacheMisses.WithLabelValues(set).Inc()
+		} else {
+			cacheErrors.WithLabelValues(set, "get").Inc()
+		}
+
 		return nil, err
 	}
 
 	if data != nil {
 		err = json.Unmarshal(data.Bins["val"].([]byte), &container)
 		if err != nil {
+			cacheErrors.WithLabelValues(set, "decode").Inc()
+
 			return nil, err
 		}
 
+		cacheHits.WithLabelValues(set).Inc()
+
 		return container, nil
 	}
+
+	cacheMisses.WithLabelValues(set).Inc()
+
 	return nil, nil
 
 }
 
-func (cache *Aerospike) Close() {
-	cache.client.Close()
+// Delete removes the record of key, a missing record is not an error.
+func (cache *Aerospike) Delete(set string, key string) error {
+	if !cache.enabled {
+		return nil
+	}
+
+	if cache.client == nil {
+		return errors.New("Client is nil for given Aerospike instance")
+	}
+
+	asKey, err := as.NewKey(cache.namespace, set, key)
+	if err != nil {
+		return err
+	}
+
+	if _, err := cache.client.Delete(nil, asKey); err != nil {
+		cacheErrors.WithLabelValues(set, "delete").Inc()
+
+		return err
+	}
+
+	return nil
 }
 
-func getHosts() []string {
-	hosts := strings.Split(os.Getenv("AEROSPIKE_HOSTS"), ",")
-	return hosts
+// Ping reports whether the client is connected to at least one node.
+func (cache *Aerospike) Ping() error {
+	if cache.client == nil {
+		return errors.New("Client is nil for given Aerospike instance")
+	}
+
+	if !cache.client.IsConnected() {
+		return errors.New("not connected to any Aerospike node")
+	}
+
+	return nil
+}
+
+func (cache *Aerospike) Close() {
+	cache.client.Close()
 }
@@ -3,14 +3,10 @@ package cache
 import (
 	"fmt"
 	"log"
-	"os"
-	"path"
-	"path/filepath"
 	"reflect"
-	"runtime"
 	"testing"
 
-	"github.com/joho/godotenv"
+	"restapi/config"
 )
 
 var aerospike *Aerospike
@@ -26,7 +22,8 @@ func TestAerospike_GetSetJson(t *testing.T) {
 		},
 	}
 
-	var ar Aerospike
+	// enabled, but never connected
+	ar := Aerospike{enabled: true}
 
 	for key, data := range toBeCached {
 		err := ar.SetJson("test", key, data, 1)
@@ -85,15 +82,10 @@ func Benchmark_Aerospike(b *testing.B) {
 }
 
 func init() {
-	if len(os.Getenv("CACHE")) == 0 {
-		_, b, _, _ := runtime.Caller(0)
-		basepath := filepath.Dir(b)
-		ap := path.Join(basepath, "../", ".development.env")
-
-		if err := godotenv.Load(ap); err != nil {
-			log.Fatalf("%s", err)
-		}
+	cfg, err := config.Load("development")
+	if err != nil {
+		log.Fatalf("%s", err)
 	}
 
-	aerospike = NewAerospikeCache()
+	aerospike = NewAerospikeCacheFromConfig(cfg.Cache)
 }
@@ -3,4 +3,5 @@ package cache
 type Cache interface {
 	SetJson(set string, key string, data interface{}, expiration int) error
 	GetJson(set string, key string, container interface{}) (interface{}, error)
+	Delete(set string, key string) error
 }
@@ -0,0 +1,28 @@
+package cache
+
+import (
+	ast "github.com/aerospike/aerospike-client-go/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	cacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "aerospike_cache_hits_total",
+		Help: "Cache reads that found a record by set.",
+	}, []string{"set"})
+	cacheMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "aerospike_cache_misses_total",
+		Help: "Cache reads that found no record by set.",
+	}, []string{"set"})
+	cacheErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "aerospike_cache_errors_total",
+		Help: "Failed cache operations by set and operation.",
+	}, []string{"set", "operation"})
+)
+
+func isKeyNotFound(err error) bool {
+	asErr, ok := err.(ast.AerospikeError)
+
+	return ok && asErr.ResultCode() == ast.KEY_NOT_FOUND_ERROR
+}
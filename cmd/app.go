@@ -1,20 +1,11 @@
 package main
 
 import (
-	"flag"
-	"log"
 	"os"
-	"restapi/internal/server"
+
+	"restapi/internal/cli"
 )
 
 func main() {
-	// start app server here
-	environment := flag.String("e", "development", "")
-	flag.Usage = func() {
-		log.Println("Usage: server -e {mode}")
-		os.Exit(1)
-	}
-
-	flag.Parse()
-	server.Init("." + *environment + ".env")
+	os.Exit(cli.Main(os.Args[1:]))
 }
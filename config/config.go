@@ -0,0 +1,452 @@
+// Package config loads the service settings into a typed Config.
+//
+// Every setting is named after its environment variable. Values are merged
+// from, lowest precedence first:
+//
+//  1. the defaults of this package
+//  2. an optional YAML or JSON file, CONFIG_FILE or {dir}/{environment}.yaml,
+//     .yml or .json, where nested keys are joined with "_", so
+//     master: {dbhost: db-1} sets MASTER_DBHOST
+//  3. the env file {dir}/.{environment}.env
+//  4. the process environment
+//
+// {dir} is CONFIG_DIR, or the first config directory found next to the
+// working directory, one of its parents, or the executable. All files are
+// optional, a container can be configured through its environment alone.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Values are raw settings keyed by environment variable name.
+type Values map[string]string
+
+// Environ returns the process environment as Values.
+func Environ() Values {
+	values := make(Values)
+
+	for _, pair := range os.Environ() {
+		if name, value, ok := strings.Cut(pair, "="); ok {
+			values[name] = value
+		}
+	}
+
+	return values
+}
+
+// Names returns the setting names, sorted.
+func (v Values) Names() []string {
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Config is the typed view of the settings.
+type Config struct {
+	Environment string
+	// Sources lists the files that were read, lowest precedence first
+	Sources []string
+
+	Server  Server
+	Log     Log
+	Replica Database
+	Master  Database
+	Cache   Cache
+	Auth    Auth
+	Outbox  Outbox
+	// Migrations is only used by the migrate command
+	Migrations Migrations
+	// Kafka is the cluster named by KAFKA_PREFIX, nil when it is not set
+	Kafka *Kafka
+
+	values Values
+	// named are the settings named in a config file or read by this package
+	named map[string]bool
+	errs  []error
+}
+
+// Load reads the settings of environment, given either as a name like
+// "test" or as the env file name ".test.env" the older constructors pass
+// around. The error reports every malformed value at once. Missing required
+// settings are only reported by Validate, since not every binary needs
+// every section.
+func Load(environment string) (*Config, error) {
+	environment = Environment(environment)
+	dir := Dir()
+
+	values := make(Values)
+	sources := make([]string, 0, 2)
+
+	process := Environ()
+
+	file := process["CONFIG_FILE"]
+	if file == "" {
+		file = findFile(dir, environment)
+	}
+
+	envFile := EnvFile(environment)
+
+	var fileValues, envValues Values
+
+	if _, err := os.Stat(envFile); err == nil {
+		envValues, err = godotenv.Read(envFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", envFile, err)
+		}
+
+		if file == "" && envValues["CONFIG_FILE"] != "" {
+			file = envValues["CONFIG_FILE"]
+		}
+	}
+
+	if file != "" {
+		var err error
+
+		fileValues, err = readFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", file, err)
+		}
+
+		sources = append(sources, file)
+	}
+
+	if envValues != nil {
+		sources = append(sources, envFile)
+	}
+
+	for _, layer := range []Values{fileValues, envValues, process} {
+		for name, value := range layer {
+			values[name] = value
+		}
+	}
+
+	cfg := Parse(values)
+	cfg.Environment = environment
+	cfg.Sources = sources
+
+	for _, layer := range []Values{fileValues, envValues} {
+		for name := range layer {
+			cfg.named[name] = true
+		}
+	}
+
+	return cfg, errors.Join(cfg.errs...)
+}
+
+// Parse builds a Config out of already merged values, malformed values are
+// recorded and reported by Load.
+func Parse(values Values) *Config {
+	r := &reader{values: values, named: make(map[string]bool)}
+
+	cfg := &Config{
+		Server:     readServer(r),
+		Log:        readLog(r),
+		Replica:    readDatabase(r, ""),
+		Master:     readDatabase(r, "MASTER"),
+		Cache:      readCache(r),
+		Auth:       readAuth(r),
+		Outbox:     readOutbox(r),
+		Migrations: readMigrations(r),
+		values:     values,
+	}
+
+	if prefix := r.string("KAFKA_PREFIX"); prefix != "" {
+		kafka := readKafka(r, prefix)
+		cfg.Kafka = &kafka
+	}
+
+	cfg.named = r.named
+	cfg.errs = r.errs
+
+	return cfg
+}
+
+// Database returns the MySQL settings stored under prefix, e.g. "MASTER"
+// for MASTER_DBHOST, or "" for the unprefixed replica settings.
+func (cfg *Config) Database(prefix string) Database {
+	return readDatabase(&reader{values: cfg.values, named: cfg.named}, prefix)
+}
+
+// KafkaCluster returns the Kafka settings stored under prefix, e.g.
+// "DOPAMINE" for DOPAMINE_KAFKA_BROKER.
+func (cfg *Config) KafkaCluster(prefix string) Kafka {
+	return readKafka(&reader{values: cfg.values, named: cfg.named}, prefix)
+}
+
+// Value returns one raw setting.
+func (cfg *Config) Value(name string) string {
+	return cfg.values[name]
+}
+
+// Settings returns the effective value of every setting named in a config
+// file or read by this package, defaults included. Unrelated variables of
+// the process environment are left out.
+func (cfg *Config) Settings() Values {
+	r := &reader{values: cfg.values}
+
+	settings := make(Values, len(cfg.named))
+	for name := range cfg.named {
+		settings[name] = r.string(name)
+	}
+
+	return settings
+}
+
+// Validate reports every missing or inconsistent setting the HTTP server
+// needs.
+func (cfg *Config) Validate() error {
+	errs := []error{
+		cfg.Server.Validate(),
+		cfg.Replica.Validate(),
+		cfg.Master.Validate(),
+		cfg.Cache.Validate(),
+		cfg.Auth.Validate(),
+		cfg.Outbox.Validate(),
+	}
+
+	if cfg.Kafka != nil {
+		errs = append(errs, cfg.Kafka.Validate())
+	}
+
+	return errors.Join(errs...)
+}
+
+// Environment normalises ".test.env" to "test", an empty value means
+// development.
+func Environment(environment string) string {
+	environment = strings.TrimSuffix(strings.TrimPrefix(environment, "."), ".env")
+	if environment == "" {
+		return "development"
+	}
+
+	return environment
+}
+
+// EnvFile is the path of the env file of environment.
+func EnvFile(environment string) string {
+	return filepath.Join(Dir(), "."+Environment(environment)+".env")
+}
+
+// Dir is the directory config files are read from.
+func Dir() string {
+	if dir := os.Getenv("CONFIG_DIR"); dir != "" {
+		return dir
+	}
+
+	candidates := make([]string, 0)
+
+	if wd, err := os.Getwd(); err == nil {
+		for dir := wd; ; dir = filepath.Dir(dir) {
+			candidates = append(candidates, filepath.Join(dir, "config"))
+
+			if filepath.Dir(dir) == dir {
+				break
+			}
+		}
+	}
+
+	if executable, err := os.Executable(); err == nil {
+		candidates = append(candidates, filepath.Join(filepath.Dir(executable), "config"))
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return candidate
+		}
+	}
+
+	return "config"
+}
+
+func findFile(dir string, environment string) string {
+	for _, extension := range []string{".yaml", ".yml", ".json"} {
+		file := filepath.Join(dir, environment+extension)
+		if _, err := os.Stat(file); err == nil {
+			return file
+		}
+	}
+
+	return ""
+}
+
+// readFile reads a YAML or JSON document and flattens it into Values.
+func readFile(file string) (Values, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var document map[string]interface{}
+
+	if strings.HasSuffix(file, ".json") {
+		err = json.Unmarshal(content, &document)
+	} else {
+		err = yaml.Unmarshal(content, &document)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(Values)
+	flatten(values, "", document)
+
+	return values, nil
+}
+
+func flatten(values Values, prefix string, node interface{}) {
+	switch node := node.(type) {
+	case map[string]interface{}:
+		for key, child := range node {
+			name := strings.ToUpper(key)
+			if prefix != "" {
+				name = prefix + "_" + name
+			}
+
+			flatten(values, name, child)
+		}
+	case []interface{}:
+		items := make([]string, 0, len(node))
+		for _, item := range node {
+			items = append(items, fmt.Sprint(item))
+		}
+
+		values[prefix] = strings.Join(items, ",")
+	case nil:
+		values[prefix] = ""
+	default:
+		values[prefix] = fmt.Sprint(node)
+	}
+}
+
+// reader converts raw values and collects the ones that do not parse.
+type reader struct {
+	values Values
+	named  map[string]bool
+	errs   []error
+}
+
+// string returns the value of name, or its default when it is unset or
+// empty.
+func (r *reader) string(name string) string {
+	if r.named != nil {
+		r.named[name] = true
+	}
+
+	if value := strings.TrimSpace(r.values[name]); value != "" {
+		return value
+	}
+
+	return defaults[name]
+}
+
+func (r *reader) list(name string) []string {
+	items := make([]string, 0)
+
+	for _, item := range strings.Split(r.string(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+func (r *reader) int(name string) int {
+	raw := r.string(name)
+
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s: %q is not a whole number", name, raw))
+	}
+
+	return value
+}
+
+func (r *reader) float(name string) float64 {
+	raw := r.string(name)
+
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s: %q is not a number", name, raw))
+	}
+
+	return value
+}
+
+func (r *reader) bool(name string) bool {
+	raw := r.string(name)
+
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s: %q is not true or false", name, raw))
+	}
+
+	return value
+}
+
+// seconds reads a duration given in whole seconds, or in Go notation like
+// "1m30s".
+func (r *reader) seconds(name string) time.Duration {
+	raw := r.string(name)
+
+	value, err := parseSeconds(raw)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s: %q is not a number of seconds", name, raw))
+	}
+
+	return value
+}
+
+func parseSeconds(raw string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(raw); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	return time.ParseDuration(raw)
+}
+
+// durations reads "key=seconds,..." pairs, the seconds given like those
+// of seconds.
+func (r *reader) durations(name string) map[string]time.Duration {
+	values := make(map[string]time.Duration)
+
+	for _, item := range r.list(name) {
+		key, raw, ok := strings.Cut(item, "=")
+		key, raw = strings.TrimSpace(key), strings.TrimSpace(raw)
+
+		value, err := parseSeconds(raw)
+		if !ok || key == "" || err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s: %q is not key=seconds", name, item))
+
+			continue
+		}
+
+		values[key] = value
+	}
+
+	return values
+}
+
+func required(errs *[]error, name string, value string) {
+	if value == "" {
+		*errs = append(*errs, fmt.Errorf("%s is required", name))
+	}
+}
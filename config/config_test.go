@@ -0,0 +1,166 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, dir string, name string, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CONFIG_DIR", dir)
+
+	writeFile(t, dir, "test.yaml", `
+server_port: ":1"
+master:
+  dbhost: [master-1, master-2]
+  dbuser: yaml
+log_level: debug
+`)
+	writeFile(t, dir, ".test.env", "SERVER_PORT=:2\nMASTER_DBUSER=env\n")
+
+	t.Setenv("SERVER_PORT", ":3")
+
+	cfg, err := Load(".test.env")
+	if err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+
+	if cfg.Environment != "test" {
+		t.Errorf("Environment: got %q", cfg.Environment)
+	}
+
+	if cfg.Server.Addr != ":3" {
+		t.Errorf("process env should win, got %q", cfg.Server.Addr)
+	}
+
+	if cfg.Master.User != "env" {
+		t.Errorf("env file should win over yaml, got %q", cfg.Master.User)
+	}
+
+	if cfg.Log.Level != "debug" {
+		t.Errorf("yaml value lost, got %q", cfg.Log.Level)
+	}
+
+	if got := strings.Join(cfg.Master.Hosts, ","); got != "master-1,master-2" {
+		t.Errorf("yaml list: got %q", got)
+	}
+
+	if cfg.Server.ShutdownDrain != 5*time.Second || cfg.Master.MaxOpenConns != 10 {
+		t.Errorf("defaults not applied: %v %d", cfg.Server.ShutdownDrain, cfg.Master.MaxOpenConns)
+	}
+}
+
+func TestLoad_ReportsEveryProblem(t *testing.T) {
+	t.Setenv("CONFIG_DIR", t.TempDir())
+	t.Setenv("MAXCONNECT", "many")
+	t.Setenv("CACHE", "yes please")
+
+	_, err := Load("test")
+	if err == nil {
+		t.Fatal("expected malformed values to fail")
+	}
+
+	for _, name := range []string{"MAXCONNECT", "CACHE"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error does not mention %s: %s", name, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Parse(Values{
+		"SERVER_PORT":                ":7000",
+		"DBHOST":                     "replica-1:3306,replica-2:3306",
+		"DBUSER":                     "reader",
+		"DBNAME":                     "restapi",
+		"MASTER_DBHOST":              "master",
+		"MASTER_DBENCRYPTEDPASSWORD": "abc",
+		"CACHE":                      "true",
+		"INTERNAL_API_KEY":           "secret",
+	})
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+
+	for _, want := range []string{
+		"MASTER_DBUSER is required",
+		"MASTER_DBNAME is required",
+		"MASTER_DBPORT is required",
+		"DB_ENCRYPTION_SECRET_KEY is not",
+		"AEROSPIKE_HOSTS is required",
+		"AEROSPIKE_NAMESPACE is required",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in:\n%s", want, err)
+		}
+	}
+
+	if strings.Contains(err.Error(), "SERVER_PORT") || strings.Contains(err.Error(), "\nDBUSER") {
+		t.Errorf("valid settings reported:\n%s", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := Parse(Values{"LOG_LEVEL": "info", "JWT_SECRET": "a", "DBHOST": "db-1"})
+	updated := Parse(Values{"LOG_LEVEL": "debug", "JWT_SECRET": "b", "DBHOST": "db-2"})
+
+	want := []Change{
+		{Name: "DBHOST", Old: "db-1", New: "db-2", Restart: true},
+		{Name: "JWT_SECRET", Old: "[REDACTED]", New: "[REDACTED]"},
+		{Name: "LOG_LEVEL", Old: "info", New: "debug"},
+	}
+
+	got := Diff(old, updated)
+	if len(got) != len(want) {
+		t.Fatalf("got %v\nwant %v", got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %v, want %v", got[i], want[i])
+		}
+	}
+}
+
+func TestServerTimeout(t *testing.T) {
+	cfg := Parse(Values{
+		"REQUEST_TIMEOUT_SECONDS": "20",
+		"ROUTE_TIMEOUTS":          "GET /api/v1/transaction/all=1m, POST /api/v1/transaction=5",
+	})
+
+	if got := cfg.Server.Timeout("GET", "/api/v1/transaction/all"); got != time.Minute {
+		t.Errorf("route override: got %v", got)
+	}
+
+	if got := cfg.Server.Timeout("POST", "/api/v1/transaction"); got != 5*time.Second {
+		t.Errorf("route override in seconds: got %v", got)
+	}
+
+	if got := cfg.Server.Timeout("GET", "/api/v1/transaction/:id"); got != 20*time.Second {
+		t.Errorf("default: got %v", got)
+	}
+
+	if got := cfg.Server.MaxTimeout(); got != time.Minute {
+		t.Errorf("MaxTimeout: got %v", got)
+	}
+
+	t.Setenv("CONFIG_DIR", t.TempDir())
+	t.Setenv("ROUTE_TIMEOUTS", "GET /api/v1/transaction/all")
+
+	if _, err := Load("test"); err == nil || !strings.Contains(err.Error(), "ROUTE_TIMEOUTS") {
+		t.Errorf("expected a malformed ROUTE_TIMEOUTS to fail, got %v", err)
+	}
+}
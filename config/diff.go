@@ -0,0 +1,85 @@
+package config
+
+import "strings"
+
+// Runtime lists the settings a running server applies on reload, every
+// other change needs a restart.
+var Runtime = map[string]bool{
+	"LOG_LEVEL":                true,
+	"MAXCONNECT":               true,
+	"MAXIDLECONNECT":           true,
+	"DB_QUERY_TIMEOUT_SECONDS": true,
+	"INTERNAL_API_KEYS":        true,
+	"INTERNAL_API_KEY":         true,
+	"INTERNAL_API_KEYS_FILE":   true,
+	"HMAC_MAX_SKEW_SECONDS":    true,
+	"JWT_SECRET":               true,
+	"JWT_JWKS_FILE":            true,
+	"JWT_AUDIENCE":             true,
+	"JWT_ISSUER":               true,
+	"JWT_ROLES_CLAIM":          true,
+	"JWT_LEEWAY_SECONDS":       true,
+	"RATE_LIMIT_RPS":           true,
+	"RATE_LIMIT_BURST":         true,
+}
+
+// secretMarkers flag the settings whose values are never shown.
+var secretMarkers = []string{"PASSWORD", "SECRET", "KEY", "TOKEN"}
+
+// Redact hides the value of settings that look like credentials.
+func Redact(name string, value string) string {
+	if value == "" {
+		return value
+	}
+
+	upper := strings.ToUpper(name)
+	for _, marker := range secretMarkers {
+		if strings.Contains(upper, marker) {
+			return "[REDACTED]"
+		}
+	}
+
+	return value
+}
+
+// Change is one setting that differs between two configs, with redacted
+// values.
+type Change struct {
+	Name string `json:"Name"`
+	Old  string `json:"Old"`
+	New  string `json:"New"`
+	// Restart is set for settings that only take effect after a restart
+	Restart bool `json:"Restart,omitempty"`
+}
+
+// Diff lists the settings that differ from old to updated, sorted by name.
+// A changed secret shows up with both values redacted.
+func Diff(old *Config, updated *Config) []Change {
+	before, after := old.Settings(), updated.Settings()
+
+	names := make(Values, len(before)+len(after))
+	for name := range before {
+		names[name] = ""
+	}
+
+	for name := range after {
+		names[name] = ""
+	}
+
+	changes := make([]Change, 0)
+
+	for _, name := range names.Names() {
+		if before[name] == after[name] {
+			continue
+		}
+
+		changes = append(changes, Change{
+			Name:    name,
+			Old:     Redact(name, before[name]),
+			New:     Redact(name, after[name]),
+			Restart: !Runtime[name],
+		})
+	}
+
+	return changes
+}
@@ -0,0 +1,418 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// defaults apply to settings that are unset or empty.
+var defaults = map[string]string{
+	"GIN_MODE":                   "debug",
+	"SHUTDOWN_DRAIN_SECONDS":     "5",
+	"REQUEST_TIMEOUT_SECONDS":    "150",
+	"IDEMPOTENCY_TTL_SECONDS":    "86400",
+	"DB_QUERY_TIMEOUT_SECONDS":   "0",
+	"LOG_DIR":                    "logs",
+	"MAXCONNECT":                 "10",
+	"MAXIDLECONNECT":             "3",
+	"CACHE":                      "false",
+	"ACTION_COUNT_CACHE_SECONDS": "60",
+	"HMAC_MAX_SKEW_SECONDS":      "300",
+	"JWT_LEEWAY_SECONDS":         "30",
+	"RATE_LIMIT_RPS":             "0",
+	"RATE_LIMIT_BURST":           "0",
+	"OUTBOX_TRANSACTION_TOPIC":   "transaction-events",
+	"OUTBOX_POLL_SECONDS":        "1",
+	"OUTBOX_BATCH_SIZE":          "100",
+	"OUTBOX_RETENTION_SECONDS":   "604800",
+	"MIGRATIONS_DIR":             "db/migrations/sql",
+}
+
+// Server configures the HTTP listener.
+type Server struct {
+	// Addr is SERVER_PORT, e.g. ":7000"
+	Addr    string
+	GinMode string
+	// ShutdownDrain is how long readiness fails before the listener closes
+	ShutdownDrain time.Duration
+	// RequestTimeout is the deadline of a request, RouteTimeouts overrides
+	// it per "METHOD /path" as the router spells the path
+	RequestTimeout time.Duration
+	RouteTimeouts  map[string]time.Duration
+	// IdempotencyTTL is how long responses are replayed to requests that
+	// repeat an Idempotency-Key
+	IdempotencyTTL time.Duration
+}
+
+func readServer(r *reader) Server {
+	return Server{
+		Addr:           r.string("SERVER_PORT"),
+		GinMode:        r.string("GIN_MODE"),
+		ShutdownDrain:  r.seconds("SHUTDOWN_DRAIN_SECONDS"),
+		RequestTimeout: r.seconds("REQUEST_TIMEOUT_SECONDS"),
+		RouteTimeouts:  r.durations("ROUTE_TIMEOUTS"),
+		IdempotencyTTL: r.seconds("IDEMPOTENCY_TTL_SECONDS"),
+	}
+}
+
+// Timeout is the deadline of requests to the route path, e.g.
+// "/api/v1/transaction/:id".
+func (s Server) Timeout(method string, path string) time.Duration {
+	if timeout, ok := s.RouteTimeouts[method+" "+path]; ok {
+		return timeout
+	}
+
+	return s.RequestTimeout
+}
+
+// MaxTimeout is the longest deadline of any route.
+func (s Server) MaxTimeout() time.Duration {
+	longest := s.RequestTimeout
+
+	for _, timeout := range s.RouteTimeouts {
+		if timeout > longest {
+			longest = timeout
+		}
+	}
+
+	return longest
+}
+
+func (s Server) Validate() error {
+	var errs []error
+
+	required(&errs, "SERVER_PORT", s.Addr)
+
+	if s.ShutdownDrain < 0 {
+		errs = append(errs, errors.New("SHUTDOWN_DRAIN_SECONDS cannot be negative"))
+	}
+
+	if s.RequestTimeout <= 0 {
+		errs = append(errs, errors.New("REQUEST_TIMEOUT_SECONDS must be positive"))
+	}
+
+	if s.IdempotencyTTL <= 0 {
+		errs = append(errs, errors.New("IDEMPOTENCY_TTL_SECONDS must be positive"))
+	}
+
+	for route, timeout := range s.RouteTimeouts {
+		if timeout <= 0 {
+			errs = append(errs, errors.New("ROUTE_TIMEOUTS: "+route+" must be positive"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+type Log struct {
+	Level string
+	Dir   string
+}
+
+func readLog(r *reader) Log {
+	return Log{
+		Level: r.string("LOG_LEVEL"),
+		Dir:   r.string("LOG_DIR"),
+	}
+}
+
+// Database holds the {PREFIX}_DB* settings of one MySQL cluster.
+type Database struct {
+	// Prefix is "" for the replicas and "MASTER" for the master
+	Prefix string
+	User   string
+	// Password is used as is unless EncryptedPassword is set, which is
+	// decrypted with EncryptionKey
+	Password          string
+	EncryptedPassword string
+	EncryptionKey     string
+	// Hosts are "host" or "host:port", replicas may list several
+	Hosts []string
+	Port  string
+	Name  string
+	// MaxOpenConns and MaxIdleConns are shared by every cluster
+	MaxOpenConns int
+	MaxIdleConns int
+	// QueryTimeout bounds every query on top of the request deadline, 0
+	// leaves queries to the request deadline alone
+	QueryTimeout time.Duration
+}
+
+func readDatabase(r *reader, prefix string) Database {
+	key := prefix
+	if key != "" {
+		key += "_"
+	}
+
+	return Database{
+		Prefix:            prefix,
+		User:              r.string(key + "DBUSER"),
+		Password:          r.string(key + "DBPASSWORD"),
+		EncryptedPassword: r.string(key + "DBENCRYPTEDPASSWORD"),
+		EncryptionKey:     r.string("DB_ENCRYPTION_SECRET_KEY"),
+		Hosts:             r.list(key + "DBHOST"),
+		Port:              r.string(key + "DBPORT"),
+		Name:              r.string(key + "DBNAME"),
+		MaxOpenConns:      r.int("MAXCONNECT"),
+		MaxIdleConns:      r.int("MAXIDLECONNECT"),
+		QueryTimeout:      r.seconds("DB_QUERY_TIMEOUT_SECONDS"),
+	}
+}
+
+// Host is the first configured host, the one a single handle connects to.
+func (d Database) Host() string {
+	if len(d.Hosts) == 0 {
+		return ""
+	}
+
+	return d.Hosts[0]
+}
+
+func (d Database) Validate() error {
+	var errs []error
+
+	key := d.Prefix
+	if key != "" {
+		key += "_"
+	}
+
+	required(&errs, key+"DBUSER", d.User)
+	required(&errs, key+"DBHOST", d.Host())
+	required(&errs, key+"DBNAME", d.Name)
+
+	for _, host := range d.Hosts {
+		if d.Port == "" && !strings.Contains(host, ":") {
+			errs = append(errs, errors.New(key+"DBPORT is required unless every host carries its port"))
+
+			break
+		}
+	}
+
+	if d.QueryTimeout < 0 {
+		errs = append(errs, errors.New("DB_QUERY_TIMEOUT_SECONDS cannot be negative"))
+	}
+
+	if d.EncryptedPassword != "" && d.EncryptionKey == "" {
+		errs = append(errs, errors.New(key+"DBENCRYPTEDPASSWORD is set but DB_ENCRYPTION_SECRET_KEY is not"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Cache configures Aerospike, which is only used when Enabled.
+type Cache struct {
+	Enabled   bool
+	Hosts     []string
+	Namespace string
+	// ActionCountTTL is how long the count of a user's recent actions is
+	// cached, inserts invalidate it earlier
+	ActionCountTTL time.Duration
+}
+
+func readCache(r *reader) Cache {
+	return Cache{
+		Enabled:        r.bool("CACHE"),
+		Hosts:          r.list("AEROSPIKE_HOSTS"),
+		Namespace:      r.string("AEROSPIKE_NAMESPACE"),
+		ActionCountTTL: r.seconds("ACTION_COUNT_CACHE_SECONDS"),
+	}
+}
+
+func (c Cache) Validate() error {
+	if c.ActionCountTTL < 0 {
+		return errors.New("ACTION_COUNT_CACHE_SECONDS cannot be negative")
+	}
+
+	if !c.Enabled {
+		return nil
+	}
+
+	var errs []error
+
+	if len(c.Hosts) == 0 {
+		errs = append(errs, errors.New("AEROSPIKE_HOSTS is required when CACHE is true"))
+	}
+
+	for _, host := range c.Hosts {
+		if !strings.Contains(host, ":") {
+			errs = append(errs, errors.New("AEROSPIKE_HOSTS: "+host+" has no port"))
+		}
+	}
+
+	if c.Namespace == "" {
+		errs = append(errs, errors.New("AEROSPIKE_NAMESPACE is required when CACHE is true"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Outbox configures the events written to the outbox table and the relay
+// publishing them to Kafka.
+type Outbox struct {
+	// TransactionTopic receives the events of transactions
+	TransactionTopic string
+	PollInterval     time.Duration
+	BatchSize        int
+	// Retention is how long sent events are kept before they are purged
+	Retention time.Duration
+}
+
+func readOutbox(r *reader) Outbox {
+	return Outbox{
+		TransactionTopic: r.string("OUTBOX_TRANSACTION_TOPIC"),
+		PollInterval:     r.seconds("OUTBOX_POLL_SECONDS"),
+		BatchSize:        r.int("OUTBOX_BATCH_SIZE"),
+		Retention:        r.seconds("OUTBOX_RETENTION_SECONDS"),
+	}
+}
+
+func (o Outbox) Validate() error {
+	var errs []error
+
+	required(&errs, "OUTBOX_TRANSACTION_TOPIC", o.TransactionTopic)
+
+	if o.PollInterval <= 0 {
+		errs = append(errs, errors.New("OUTBOX_POLL_SECONDS must be positive"))
+	}
+
+	if o.BatchSize <= 0 {
+		errs = append(errs, errors.New("OUTBOX_BATCH_SIZE must be positive"))
+	}
+
+	if o.Retention <= 0 {
+		errs = append(errs, errors.New("OUTBOX_RETENTION_SECONDS must be positive"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Kafka holds the {PREFIX}_KAFKA_* settings of one cluster.
+type Kafka struct {
+	Prefix  string
+	Version string
+	Brokers []string
+	Group   string
+	Topic   string
+}
+
+func readKafka(r *reader, prefix string) Kafka {
+	return Kafka{
+		Prefix:  prefix,
+		Version: r.string(prefix + "_KAFKA_VERSION"),
+		Brokers: r.list(prefix + "_KAFKA_BROKER"),
+		Group:   r.string(prefix + "_KAFKA_GROUP"),
+		Topic:   r.string(prefix + "_KAFKA_TOPIC"),
+	}
+}
+
+func (k Kafka) Validate() error {
+	var errs []error
+
+	required(&errs, k.Prefix+"_KAFKA_VERSION", k.Version)
+
+	if len(k.Brokers) == 0 {
+		errs = append(errs, errors.New(k.Prefix+"_KAFKA_BROKER is required"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Auth configures who may call the API and how often.
+type Auth struct {
+	APIKeys   APIKeys
+	JWT       JWT
+	RateLimit RateLimit
+}
+
+// RateLimit caps the requests of every caller with a token bucket holding
+// Burst tokens and refilled with PerSecond tokens a second. A PerSecond of
+// 0 disables it.
+type RateLimit struct {
+	PerSecond float64
+	Burst     int
+}
+
+// APIKeys are the credentials of internal callers, see
+// middlewares.APIKeyStore for the formats.
+type APIKeys struct {
+	// Keys is INTERNAL_API_KEYS, "name:key[:signed][:admin],..."
+	Keys string
+	// Legacy is the single INTERNAL_API_KEY, named "default"
+	Legacy string
+	File   string
+	// MaxSkew is the clock skew allowed for signed requests
+	MaxSkew time.Duration
+}
+
+// JWT configures bearer token validation. Secret enables HS256 and
+// JWKSFile enables RS256.
+type JWT struct {
+	Secret     string
+	JWKSFile   string
+	Audience   string
+	Issuer     string
+	RolesClaim string
+	Leeway     time.Duration
+}
+
+// Enabled reports whether any verification key is configured.
+func (j JWT) Enabled() bool {
+	return j.Secret != "" || j.JWKSFile != ""
+}
+
+func readAuth(r *reader) Auth {
+	return Auth{
+		APIKeys: APIKeys{
+			Keys:    r.string("INTERNAL_API_KEYS"),
+			Legacy:  r.string("INTERNAL_API_KEY"),
+			File:    r.string("INTERNAL_API_KEYS_FILE"),
+			MaxSkew: r.seconds("HMAC_MAX_SKEW_SECONDS"),
+		},
+		JWT: JWT{
+			Secret:     r.string("JWT_SECRET"),
+			JWKSFile:   r.string("JWT_JWKS_FILE"),
+			Audience:   r.string("JWT_AUDIENCE"),
+			Issuer:     r.string("JWT_ISSUER"),
+			RolesClaim: r.string("JWT_ROLES_CLAIM"),
+			Leeway:     r.seconds("JWT_LEEWAY_SECONDS"),
+		},
+		RateLimit: RateLimit{
+			PerSecond: r.float("RATE_LIMIT_RPS"),
+			Burst:     r.int("RATE_LIMIT_BURST"),
+		},
+	}
+}
+
+func (a Auth) Validate() error {
+	var errs []error
+
+	if a.APIKeys.Keys == "" && a.APIKeys.Legacy == "" && a.APIKeys.File == "" && !a.JWT.Enabled() {
+		errs = append(errs, errors.New("no credentials configured, set INTERNAL_API_KEYS, INTERNAL_API_KEYS_FILE or JWT_SECRET"))
+	}
+
+	if a.APIKeys.MaxSkew <= 0 {
+		errs = append(errs, errors.New("HMAC_MAX_SKEW_SECONDS must be positive"))
+	}
+
+	if a.JWT.Leeway < 0 {
+		errs = append(errs, errors.New("JWT_LEEWAY_SECONDS cannot be negative"))
+	}
+
+	if a.RateLimit.PerSecond < 0 || a.RateLimit.Burst < 0 {
+		errs = append(errs, errors.New("RATE_LIMIT_RPS and RATE_LIMIT_BURST cannot be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Migrations locates the SQL migrations in the source tree.
+type Migrations struct {
+	// Dir is where migrate create adds new migrations, relative to the
+	// working directory
+	Dir string
+}
+
+func readMigrations(r *reader) Migrations {
+	return Migrations{Dir: r.string("MIGRATIONS_DIR")}
+}
@@ -1,21 +1,18 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"math"
-	"os"
-	"path"
-	"path/filepath"
-	"runtime"
-	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"restapi/config"
 	"restapi/util"
 
 	"github.com/jmoiron/sqlx"
-	"github.com/joho/godotenv"
 
 	// this is necessary for specifying the driver when connecting to DB
 	_ "github.com/go-sql-driver/mysql"
@@ -31,6 +28,13 @@ type DB struct {
 	Err      error
 	db       *sql.DB
 	Dbx      *sqlx.DB
+	name     string
+	// maxOpenConn and maxIdleConn are the sizes asked for by the caller,
+	// negative ones follow the config
+	maxOpenConn int
+	maxIdleConn int
+	// queryTimeout is config.Database.QueryTimeout in nanoseconds
+	queryTimeout atomic.Int64
 }
 
 type MultiInsertHolder struct {
@@ -38,52 +42,67 @@ type MultiInsertHolder struct {
 	Data  []interface{}
 }
 
-func getDBPassword(prefix string) string {
+func getDBPassword(cfg config.Database) string {
 	// check existence of hashed password
-	hashedPassword := os.Getenv(prefix + "DBENCRYPTEDPASSWORD")
-
-	if len(hashedPassword) == 0 {
-		return os.Getenv(prefix + "DBPASSWORD")
+	if len(cfg.EncryptedPassword) == 0 {
+		return cfg.Password
 	}
 
-	response, err := util.DecryptWithRandomIV([]byte(os.Getenv("DB_ENCRYPTION_SECRET_KEY")), hashedPassword)
+	response, err := util.DecryptWithRandomIV([]byte(cfg.EncryptionKey), cfg.EncryptedPassword)
 	if err != nil {
-		log.Fatal("error decrypting password %s", err)
+		log.Fatalf("error decrypting password %s", err)
 	}
 
 	return string(response)
 }
 
+// loadConfig reads the settings of the env file, stopping the process when
+// they are malformed as the constructors using it always did.
+func loadConfig(env string) *config.Config {
+	cfg, err := config.Load(env)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	return cfg
+}
+
 // Conn : Initiation function
 // Use instance := db.Conn()
 // prefixes is used here so that you don't always have to specify an empty string
 // it is just assumed
 func Conn(env string, utf8 bool, maxOpenConn int, maxIdleConn int, prefixes ...string) *DB {
-	instance := &DB{}
-
 	prefix := ""
-
 	if len(prefixes) > 0 {
-		prefix = prefixes[0] + "_"
+		prefix = prefixes[0]
 	}
 
-	// load env if not already loaded
+	return Connect(loadConfig(env).Database(prefix), maxOpenConn, maxIdleConn)
+}
 
-	if len(os.Getenv("DBUSER")) == 0 {
-		_, b, _, _ := runtime.Caller(0)
-		basepath := filepath.Dir(b)
-		ap := path.Join(basepath, "../../config", env)
+// Connect opens a handle to the first host of cfg. A negative maxOpenConn
+// or maxIdleConn falls back to the pool sizes in cfg.
+func Connect(cfg config.Database, maxOpenConn int, maxIdleConn int) *DB {
+	return connect(cfg, cfg.Host(), maxOpenConn, maxIdleConn)
+}
 
-		if err := godotenv.Load(ap); err != nil {
-			log.Fatalf("%s", err)
-		}
+// connect opens a handle to host, given as "host" or "host:port".
+func connect(cfg config.Database, host string, maxOpenConn int, maxIdleConn int) *DB {
+	instance := &DB{
+		Username: cfg.User,
+		Dbase:    cfg.Name,
+		Host:     host,
+		Port:     cfg.Port,
+		Password: getDBPassword(cfg),
+		name:     strings.ToLower(cfg.Prefix),
+
+		maxOpenConn: maxOpenConn,
+		maxIdleConn: maxIdleConn,
 	}
 
-	instance.Username = os.Getenv(prefix + "DBUSER")
-	instance.Dbase = os.Getenv(prefix + "DBNAME")
-	instance.Host = os.Getenv(prefix + "DBHOST")
-	instance.Port = os.Getenv(prefix + "DBPORT")
-	instance.Password = getDBPassword(prefix)
+	if hostAndPort := strings.Split(host, ":"); len(hostAndPort) == 2 {
+		instance.Host, instance.Port = hostAndPort[0], hostAndPort[1]
+	}
 
 	mysqlConnString := instance.Username + ":" + instance.Password + "@tcp(" + instance.Host + ":" + instance.Port + ")/" + instance.Dbase
 
@@ -91,28 +110,7 @@ func Conn(env string, utf8 bool, maxOpenConn int, maxIdleConn int, prefixes ...s
 
 	instance.db, instance.Err = sql.Open("mysql", mysqlConnString)
 	if instance.Err == nil {
-
-		maxOpenConnections, errMaxOpen := strconv.Atoi(os.Getenv("MAXCONNECT"))
-		maxIdleConnections, errMaxIdle := strconv.Atoi(os.Getenv("MAXIDLECONNECT"))
-
-		if errMaxOpen != nil {
-			maxOpenConnections = 10
-		}
-
-		if errMaxIdle != nil {
-			maxIdleConnections = 3
-		}
-
-		if maxOpenConn >= 0 {
-			maxOpenConnections = maxOpenConn
-		}
-
-		if maxIdleConn >= 0 {
-			maxIdleConnections = maxIdleConn
-		}
-
-		instance.db.SetMaxOpenConns(maxOpenConnections)
-		instance.db.SetMaxIdleConns(maxIdleConnections)
+		instance.Resize(cfg)
 		instance.db.SetConnMaxLifetime(time.Hour)
 	} else {
 		log.Fatalf("\nError connecting to DB with connection string = %s", mysqlConnString)
@@ -122,9 +120,31 @@ func Conn(env string, utf8 bool, maxOpenConn int, maxIdleConn int, prefixes ...s
 
 	instance.Dbx = sqlx.NewDb(instance.db, "mysql")
 
+	stats.add(instance)
+
 	return instance
 }
 
+// Resize applies the pool sizes of cfg to the open handle, unless the
+// caller of Connect fixed them, and its query timeout.
+func (db *DB) Resize(cfg config.Database) {
+	db.queryTimeout.Store(int64(cfg.QueryTimeout))
+
+	maxOpenConnections := cfg.MaxOpenConns
+	maxIdleConnections := cfg.MaxIdleConns
+
+	if db.maxOpenConn >= 0 {
+		maxOpenConnections = db.maxOpenConn
+	}
+
+	if db.maxIdleConn >= 0 {
+		maxIdleConnections = db.maxIdleConn
+	}
+
+	db.db.SetMaxOpenConns(maxOpenConnections)
+	db.db.SetMaxIdleConns(maxIdleConnections)
+}
+
 // Fetch : Function that fetches through and returns first result
 //
 //	row := instance.Fetch("SELECT * FROM user WHERE Id = :Id", map[string]string{
@@ -201,6 +221,22 @@ func Conn(env string, utf8 bool, maxOpenConn int, maxIdleConn int, prefixes ...s
 // 	return 0, true
 // }
 
+// WithQueryTimeout bounds ctx by the query timeout of the handle, if any.
+// The caller must call cancel once the query is done.
+func (db *DB) WithQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := time.Duration(db.queryTimeout.Load())
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Ping verifies a connection to the database is still alive.
+func (db *DB) Ping(ctx context.Context) error {
+	return db.db.PingContext(ctx)
+}
+
 func (db *DB) ExecuteGetError(query string, args ...interface{}) (int64, bool, error) {
 	stmt, err := db.db.Prepare(query)
 	if err != nil {
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// every DB opened through Conn reports its pool statistics until it is closed
+var stats = &statsCollector{handles: map[*DB]struct{}{}}
+
+// Name identifies the handle in metrics and health reports.
+func (db *DB) Name() string {
+	if db.name == "" {
+		return "default"
+	}
+
+	return db.name
+}
+
+// Stats returns the connection pool statistics of the handle.
+func (db *DB) Stats() sql.DBStats {
+	return db.db.Stats()
+}
+
+// Close stops reporting the pool statistics of the handle and closes it.
+func (db *DB) Close() error {
+	stats.remove(db)
+
+	return db.db.Close()
+}
+
+type poolMetric struct {
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+	value     func(stats sql.DBStats) float64
+}
+
+func newPoolMetric(name string, help string, valueType prometheus.ValueType, value func(stats sql.DBStats) float64) poolMetric {
+	return poolMetric{
+		desc:      prometheus.NewDesc(name, help, []string{"db", "host"}, nil),
+		valueType: valueType,
+		value:     value,
+	}
+}
+
+var poolMetrics = []poolMetric{
+	newPoolMetric("mysql_pool_max_open_connections", "Maximum number of open connections to the database.",
+		prometheus.GaugeValue, func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }),
+	newPoolMetric("mysql_pool_open_connections", "Established connections, both in use and idle.",
+		prometheus.GaugeValue, func(s sql.DBStats) float64 { return float64(s.OpenConnections) }),
+	newPoolMetric("mysql_pool_in_use_connections", "Connections currently in use.",
+		prometheus.GaugeValue, func(s sql.DBStats) float64 { return float64(s.InUse) }),
+	newPoolMetric("mysql_pool_idle_connections", "Idle connections.",
+		prometheus.GaugeValue, func(s sql.DBStats) float64 { return float64(s.Idle) }),
+	newPoolMetric("mysql_pool_wait_count_total", "Connections waited for.",
+		prometheus.CounterValue, func(s sql.DBStats) float64 { return float64(s.WaitCount) }),
+	newPoolMetric("mysql_pool_wait_duration_seconds_total", "Time blocked waiting for a new connection.",
+		prometheus.CounterValue, func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }),
+	newPoolMetric("mysql_pool_max_idle_closed_total", "Connections closed due to SetMaxIdleConns.",
+		prometheus.CounterValue, func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }),
+	newPoolMetric("mysql_pool_max_idle_time_closed_total", "Connections closed due to SetConnMaxIdleTime.",
+		prometheus.CounterValue, func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }),
+	newPoolMetric("mysql_pool_max_lifetime_closed_total", "Connections closed due to SetConnMaxLifetime.",
+		prometheus.CounterValue, func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }),
+}
+
+// statsCollector reads the pool statistics of the open handles on every scrape.
+type statsCollector struct {
+	mu      sync.RWMutex
+	handles map[*DB]struct{}
+}
+
+func (c *statsCollector) add(instance *DB) {
+	c.mu.Lock()
+	c.handles[instance] = struct{}{}
+	c.mu.Unlock()
+}
+
+func (c *statsCollector) remove(instance *DB) {
+	c.mu.Lock()
+	delete(c.handles, instance)
+	c.mu.Unlock()
+}
+
+func (c *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, metric := range poolMetrics {
+		ch <- metric.desc
+	}
+}
+
+func (c *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for instance := range c.handles {
+		s := instance.Stats()
+
+		for _, metric := range poolMetrics {
+			ch <- prometheus.MustNewConstMetric(metric.desc, metric.valueType, metric.value(s), instance.Name(), instance.Host)
+		}
+	}
+}
+
+func init() {
+	prometheus.MustRegister(stats)
+}
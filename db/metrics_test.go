@@ -0,0 +1,30 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestStatsCollector_Close(t *testing.T) {
+	handle, err := sql.Open("mysql", "user:password@tcp(127.0.0.1:3306)/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collector := &statsCollector{handles: map[*DB]struct{}{}}
+	instance := &DB{db: handle, Host: "127.0.0.1"}
+
+	collector.add(instance)
+
+	if got := testutil.CollectAndCount(collector); got != len(poolMetrics) {
+		t.Errorf("got %d samples of an open handle, want %d", got, len(poolMetrics))
+	}
+
+	collector.remove(instance)
+
+	if got := testutil.CollectAndCount(collector); got != 0 {
+		t.Errorf("got %d samples after the handle was closed", got)
+	}
+}
@@ -0,0 +1,145 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"text/tabwriter"
+
+	"restapi/config"
+	"restapi/db"
+)
+
+// Usage describes the commands understood by Run.
+const Usage = `Commands:
+  up [-steps N]     apply pending migrations, all of them by default
+  down [-steps N]   revert the last N applied migrations, 1 by default
+  status            list migrations and whether they are applied
+  create NAME       add an empty up/down pair to the migrations directory
+`
+
+// ErrUsage is returned for invalid command lines.
+var ErrUsage = errors.New("invalid usage")
+
+// Options select the database and the migrations a command works on.
+type Options struct {
+	// Database is the cluster to migrate, usually the master
+	Database config.Database
+	// Dir reads migrations from disk instead of the embedded ones
+	Dir string
+	// SourceDir is where create adds migrations when Dir is empty, the
+	// directory Embedded is built from
+	SourceDir string
+}
+
+// Run executes one of up, down, status or create, args[0] names it.
+func Run(opts Options, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+
+	command, rest := args[0], args[1:]
+
+	if command == "create" {
+		if len(rest) != 1 {
+			return ErrUsage
+		}
+
+		target := opts.Dir
+		if target == "" {
+			target = opts.SourceDir
+		}
+
+		files, err := Create(target, rest[0])
+		for _, file := range files {
+			fmt.Fprintln(out, "created", file)
+		}
+
+		return err
+	}
+
+	steps := flag.NewFlagSet(command, flag.ContinueOnError)
+	steps.SetOutput(out)
+	count := steps.Int("steps", 0, "number of migrations")
+
+	if err := steps.Parse(rest); err != nil || steps.NArg() > 0 {
+		return ErrUsage
+	}
+
+	switch command {
+	case "up", "down", "status":
+	default:
+		return ErrUsage
+	}
+
+	var (
+		source fs.FS = Embedded
+		root         = EmbeddedDir
+	)
+
+	if opts.Dir != "" {
+		source, root = os.DirFS(opts.Dir), "."
+	}
+
+	migrations, err := Load(source, root)
+	if err != nil {
+		return err
+	}
+
+	if err := opts.Database.Validate(); err != nil {
+		return err
+	}
+
+	handle := db.Connect(opts.Database, 1, 1)
+	defer handle.Close()
+
+	migrator := NewMigrator(handle, migrations)
+	ctx := context.Background()
+
+	switch command {
+	case "up":
+		return migrator.Up(ctx, *count, func(migration Migration) {
+			fmt.Fprintf(out, "applied %04d_%s\n", migration.Version, migration.Name)
+		})
+	case "down":
+		return migrator.Down(ctx, *count, func(migration Migration) {
+			fmt.Fprintf(out, "reverted %04d_%s\n", migration.Version, migration.Name)
+		})
+	default:
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		applied := 0
+		for _, status := range statuses {
+			if status.Applied {
+				applied++
+			}
+		}
+
+		if applied == 0 {
+			fmt.Fprintln(out, "no migrations applied")
+		}
+
+		table := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(table, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+
+		for _, status := range statuses {
+			state := "pending"
+			if status.Dirty {
+				state = "dirty"
+			} else if status.Applied {
+				state = "applied"
+			}
+
+			fmt.Fprintf(table, "%04d\t%s\t%s\t%s\n", status.Version, status.Name, state, status.AppliedAt)
+		}
+
+		return table.Flush()
+	}
+}
@@ -0,0 +1,408 @@
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"restapi/db"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Embedded holds the migrations shipped with the binary.
+//
+//go:embed sql/*.sql
+var Embedded embed.FS
+
+// EmbeddedDir is where Embedded is read from, and where new migrations are
+// created during development.
+const EmbeddedDir = "sql"
+
+const (
+	lockName    = "restapi_schema_migrations"
+	lockTimeout = 60
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is one numbered schema change and the way back.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is the state of one migration in the target database.
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	Dirty     bool
+	AppliedAt string
+}
+
+// Load reads NNNN_name.up.sql / NNNN_name.down.sql pairs from dir in fsys,
+// sorted by version.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+
+	for _, migration := range byVersion {
+		if strings.TrimSpace(migration.Up) == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", migration.Version, migration.Name)
+		}
+
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Migrator applies migrations to one database. Every run holds a MySQL
+// advisory lock so that instances started together do not race.
+type Migrator struct {
+	db         *db.DB
+	migrations []Migration
+}
+
+func NewMigrator(database *db.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: database, migrations: migrations}
+}
+
+// Up applies up to steps pending migrations, all of them when steps <= 0.
+func (m *Migrator) Up(ctx context.Context, steps int, progress func(Migration)) error {
+	return m.locked(ctx, func(conn *sqlx.Conn, applied map[int64]Status) error {
+		for _, migration := range m.migrations {
+			if status, ok := applied[migration.Version]; ok {
+				if status.Dirty {
+					return dirtyError(status)
+				}
+
+				continue
+			}
+
+			if err := m.apply(ctx, conn, migration, migration.Up, true); err != nil {
+				return err
+			}
+
+			if progress != nil {
+				progress(migration)
+			}
+
+			steps--
+			if steps == 0 {
+				break
+			}
+		}
+
+		return nil
+	})
+}
+
+// Down reverts the last steps applied migrations, one when steps <= 0.
+func (m *Migrator) Down(ctx context.Context, steps int, progress func(Migration)) error {
+	if steps <= 0 {
+		steps = 1
+	}
+
+	return m.locked(ctx, func(conn *sqlx.Conn, applied map[int64]Status) error {
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := m.migrations[i]
+
+			status, ok := applied[migration.Version]
+			if !ok {
+				continue
+			}
+
+			if status.Dirty {
+				return dirtyError(status)
+			}
+
+			if strings.TrimSpace(migration.Down) == "" {
+				return fmt.Errorf("migration %d_%s cannot be reverted, it has no down script", migration.Version, migration.Name)
+			}
+
+			if err := m.apply(ctx, conn, migration, migration.Down, false); err != nil {
+				return err
+			}
+
+			if progress != nil {
+				progress(migration)
+			}
+
+			steps--
+		}
+
+		return nil
+	})
+}
+
+// Status lists every known migration along with its state in the database.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied := make(map[int64]Status)
+
+	// status only reads, without the lock a running migration holds and
+	// without creating schema_migrations, whose absence means none applied
+	var tables int
+	if err := m.db.Dbx.GetContext(ctx, &tables, `SELECT COUNT(*)
+		FROM information_schema.tables
+		WHERE table_schema = DATABASE() AND table_name = 'schema_migrations'`); err != nil {
+		return nil, err
+	}
+
+	if tables > 0 {
+		var err error
+
+		if applied, err = readApplied(ctx, m.db.Dbx); err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]Status, 0, len(m.migrations))
+
+	for _, migration := range m.migrations {
+		status, ok := applied[migration.Version]
+		if !ok {
+			status = Status{Version: migration.Version, Name: migration.Name}
+		}
+
+		result = append(result, status)
+	}
+
+	return result, nil
+}
+
+// apply runs one script. MySQL commits DDL implicitly, so the version row is
+// written as dirty first and only cleaned once every statement succeeded;
+// a failure in between leaves a dirty row that blocks further runs until
+// someone has looked at the schema.
+func (m *Migrator) apply(ctx context.Context, conn *sqlx.Conn, migration Migration, script string, up bool) error {
+	if up {
+		_, err := conn.ExecContext(ctx,
+			"INSERT INTO schema_migrations (version, name, dirty) VALUES (?, ?, 1)",
+			migration.Version, migration.Name)
+		if err != nil {
+			return err
+		}
+	} else {
+		_, err := conn.ExecContext(ctx,
+			"UPDATE schema_migrations SET dirty = 1 WHERE version = ?", migration.Version)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, statement := range SplitStatements(script) {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	var err error
+
+	if up {
+		_, err = conn.ExecContext(ctx,
+			"UPDATE schema_migrations SET dirty = 0, applied_at = NOW() WHERE version = ?", migration.Version)
+	} else {
+		_, err = conn.ExecContext(ctx,
+			"DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+	}
+
+	return err
+}
+
+// locked pins one connection, since MySQL advisory locks belong to a
+// session, takes the lock and hands the applied versions to fn.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sqlx.Conn, applied map[int64]Status) error) error {
+	conn, err := m.db.Dbx.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var acquired *int
+	if err := conn.GetContext(ctx, &acquired, "SELECT GET_LOCK(?, ?)", lockName, lockTimeout); err != nil {
+		return err
+	}
+
+	if acquired == nil || *acquired != 1 {
+		return errors.New("another migration is running, could not acquire the lock")
+	}
+
+	defer func() {
+		// nolint:errcheck
+		conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName)
+	}()
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		dirty TINYINT(1) NOT NULL DEFAULT 0,
+		applied_at DATETIME NULL,
+		PRIMARY KEY (version)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`)
+	if err != nil {
+		return err
+	}
+
+	applied, err := readApplied(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn, applied)
+}
+
+// readApplied returns the rows of schema_migrations by version.
+func readApplied(ctx context.Context, q sqlx.QueryerContext) (map[int64]Status, error) {
+	rows := make([]struct {
+		Version   int64   `db:"version"`
+		Name      string  `db:"name"`
+		Dirty     bool    `db:"dirty"`
+		AppliedAt *string `db:"applied_at"`
+	}, 0)
+
+	if err := sqlx.SelectContext(ctx, q, &rows, "SELECT version, name, dirty, applied_at FROM schema_migrations"); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]Status, len(rows))
+	for _, row := range rows {
+		status := Status{
+			Version: row.Version,
+			Name:    row.Name,
+			Applied: true,
+			Dirty:   row.Dirty,
+		}
+
+		// the DSN does not set parseTime, so the timestamp stays text
+		if row.AppliedAt != nil {
+			status.AppliedAt = *row.AppliedAt
+		}
+
+		applied[row.Version] = status
+	}
+
+	return applied, nil
+}
+
+func dirtyError(status Status) error {
+	return fmt.Errorf("migration %d_%s is dirty, a previous run failed half way, fix the schema by hand and delete its row from schema_migrations", status.Version, status.Name)
+}
+
+// SplitStatements cuts a script into statements at semicolons that end a
+// line. Lines starting with -- are comments.
+func SplitStatements(script string) []string {
+	statements := make([]string, 0)
+
+	var current strings.Builder
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+
+	return statements
+}
+
+// Create writes an empty up/down pair numbered after the highest version
+// found in dir and returns the paths of the new files.
+func Create(dir string, name string) ([]string, error) {
+	name = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), " ", "_"))
+	if !regexp.MustCompile(`^[a-z0-9_]+$`).MatchString(name) {
+		return nil, fmt.Errorf("invalid migration name %q, use letters, digits and underscores", name)
+	}
+
+	existing, err := Load(os.DirFS(dir), ".")
+	if err != nil {
+		return nil, err
+	}
+
+	version := int64(1)
+	if len(existing) > 0 {
+		version = existing[len(existing)-1].Version + 1
+	}
+
+	files := make([]string, 0, 2)
+
+	for _, direction := range []string{"up", "down"} {
+		file := filepath.Join(dir, fmt.Sprintf("%04d_%s.%s.sql", version, name, direction))
+		content := fmt.Sprintf("-- %04d_%s (%s)\n", version, name, direction)
+
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			return nil, err
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
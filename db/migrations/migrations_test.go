@@ -0,0 +1,62 @@
+package migrations
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0002_second.up.sql":  {Data: []byte("CREATE TABLE b (id INT);")},
+		"m/0001_first.up.sql":   {Data: []byte("CREATE TABLE a (id INT);")},
+		"m/0001_first.down.sql": {Data: []byte("DROP TABLE a;")},
+		"m/README.md":           {Data: []byte("ignored")},
+	}
+
+	migrations, err := Load(fsys, "m")
+	if err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+
+	if len(migrations) != 2 || migrations[0].Name != "first" || migrations[1].Version != 2 {
+		t.Fatalf("unexpected migrations: %+v", migrations)
+	}
+
+	if migrations[0].Down != "DROP TABLE a;" || migrations[1].Down != "" {
+		t.Errorf("down scripts were not paired: %+v", migrations)
+	}
+
+	fsys["m/0003_orphan.down.sql"] = &fstest.MapFile{Data: []byte("DROP TABLE c;")}
+	if _, err := Load(fsys, "m"); err == nil {
+		t.Errorf("expected an error for a migration without up script")
+	}
+}
+
+func TestLoad_Embedded(t *testing.T) {
+	if _, err := Load(Embedded, EmbeddedDir); err != nil {
+		t.Errorf("embedded migrations are invalid: %s", err)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- header
+CREATE TABLE a (
+    id INT, -- trailing comments stay
+    -- full line comments go
+    name TEXT
+);
+
+INSERT INTO a VALUES (1, 'x;y');
+UPDATE a SET name = 'z'`
+
+	want := []string{
+		"CREATE TABLE a (\n    id INT, -- trailing comments stay\n    name TEXT\n)",
+		"INSERT INTO a VALUES (1, 'x;y')",
+		"UPDATE a SET name = 'z'",
+	}
+
+	if got := SplitStatements(script); !reflect.DeepEqual(got, want) {
+		t.Errorf("Got: %q\n Want: %q", got, want)
+	}
+}
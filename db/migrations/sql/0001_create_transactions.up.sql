@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS transactions (
+    txnId INT NOT NULL AUTO_INCREMENT,
+    code VARCHAR(64) NOT NULL,
+    companyId INT NOT NULL,
+    jobProfileId INT NOT NULL,
+    actionId INT NULL,
+    userId VARCHAR(64) NULL,
+    created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (txnId),
+    -- keyset pagination walks (sort column, txnId)
+    KEY idx_transactions_company (companyId, txnId),
+    KEY idx_transactions_job_profile (jobProfileId, txnId),
+    KEY idx_transactions_code (code, txnId),
+    KEY idx_transactions_action_user (actionId, userId, created)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS actions (
+    Id INT NOT NULL AUTO_INCREMENT,
+    Code VARCHAR(64) NOT NULL,
+    Info TEXT NULL,
+    AdditionalInfo TEXT NULL,
+    CurrencyExpression VARCHAR(255) NULL,
+    Type VARCHAR(32) NOT NULL DEFAULT '',
+    Active TINYINT(1) NOT NULL DEFAULT 1,
+    Created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    Modified DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (Id),
+    UNIQUE KEY uniq_actions_code (Code)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS action_currency_rules (
+    Id INT NOT NULL AUTO_INCREMENT,
+    ActionId INT NOT NULL,
+    Type VARCHAR(32) NOT NULL,
+    Info TEXT NOT NULL,
+    ModifiedBy VARCHAR(128) NOT NULL DEFAULT '',
+    Created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (Id),
+    KEY idx_action_currency_rules_action (ActionId),
+    CONSTRAINT fk_action_currency_rules_action FOREIGN KEY (ActionId) REFERENCES actions (Id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS blacklisted_users;
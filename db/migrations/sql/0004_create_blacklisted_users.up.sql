@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS blacklisted_users (
+    UserId VARCHAR(64) NOT NULL,
+    Reason VARCHAR(255) NOT NULL DEFAULT '',
+    Created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (UserId)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,3 @@
+ALTER TABLE action_currency_rules
+    DROP COLUMN Scale,
+    DROP COLUMN Expression;
@@ -0,0 +1,5 @@
+-- a rule computes the currency of an action with a govaluate expression,
+-- rounded to Scale decimal places
+ALTER TABLE action_currency_rules
+    ADD COLUMN Expression VARCHAR(1024) NOT NULL DEFAULT '' AFTER Type,
+    ADD COLUMN Scale TINYINT NOT NULL DEFAULT 0 AFTER Expression;
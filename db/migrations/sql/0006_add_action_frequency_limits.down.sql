@@ -0,0 +1,3 @@
+ALTER TABLE actions
+    DROP COLUMN FrequencyWindowDays,
+    DROP COLUMN FrequencyLimit;
@@ -0,0 +1,5 @@
+-- a user may earn from an action at most FrequencyLimit times in any
+-- FrequencyWindowDays days, NULL leaves the action unlimited
+ALTER TABLE actions
+    ADD COLUMN FrequencyLimit INT NULL AFTER Type,
+    ADD COLUMN FrequencyWindowDays INT NULL AFTER FrequencyLimit;
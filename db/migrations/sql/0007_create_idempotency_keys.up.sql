@@ -0,0 +1,14 @@
+-- responses to requests sent with an Idempotency-Key, Status is NULL while
+-- the first request is in flight
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    Scope VARCHAR(128) NOT NULL,
+    IdempotencyKey VARCHAR(255) NOT NULL,
+    RequestHash CHAR(64) NOT NULL,
+    Status SMALLINT NULL,
+    ContentType VARCHAR(128) NULL,
+    Body MEDIUMBLOB NULL,
+    Created DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    Expires DATETIME NOT NULL,
+    PRIMARY KEY (Scope, IdempotencyKey),
+    KEY idx_idempotency_keys_expires (Expires)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,15 @@
+-- events written in the transaction of the rows they describe, published
+-- to Kafka by the outbox relay in Id order and purged once sent
+CREATE TABLE IF NOT EXISTS outbox (
+    Id BIGINT NOT NULL AUTO_INCREMENT,
+    Topic VARCHAR(255) NOT NULL,
+    MessageKey VARBINARY(255) NULL,
+    Payload MEDIUMBLOB NOT NULL,
+    Headers TEXT NULL,
+    Attempts INT NOT NULL DEFAULT 0,
+    LastError VARCHAR(1024) NULL,
+    Created DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    Sent DATETIME(3) NULL,
+    PRIMARY KEY (Id),
+    KEY idx_outbox_sent (Sent, Id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
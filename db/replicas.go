@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"restapi/config"
+	"restapi/logger"
+)
+
+// ReplicaSet spreads reads over several replica handles in round robin,
+// skipping the ones whose last health probe failed.
+type ReplicaSet struct {
+	replicas []*DB
+	healthy  []atomic.Bool
+	next     atomic.Uint64
+}
+
+func NewReplicaSet(replicas ...*DB) *ReplicaSet {
+	if len(replicas) == 0 {
+		panic("replica set needs at least one db")
+	}
+
+	rs := &ReplicaSet{
+		replicas: replicas,
+		healthy:  make([]atomic.Bool, len(replicas)),
+	}
+
+	for i := range rs.healthy {
+		rs.healthy[i].Store(true)
+	}
+
+	return rs
+}
+
+// ConnReplicas opens one handle per host listed in the comma separated
+// DBHOST of the prefix, e.g. DBHOST=replica-1,replica-2:3307. Every handle
+// gets its own pool sized by maxOpenConn and maxIdleConn.
+func ConnReplicas(env string, utf8 bool, maxOpenConn int, maxIdleConn int, prefixes ...string) *ReplicaSet {
+	prefix := ""
+	if len(prefixes) > 0 {
+		prefix = prefixes[0]
+	}
+
+	return ConnectReplicas(loadConfig(env).Database(prefix), maxOpenConn, maxIdleConn)
+}
+
+// ConnectReplicas opens one handle per host of cfg, see ConnReplicas.
+func ConnectReplicas(cfg config.Database, maxOpenConn int, maxIdleConn int) *ReplicaSet {
+	replicas := make([]*DB, 0, len(cfg.Hosts))
+
+	for _, host := range cfg.Hosts {
+		replicas = append(replicas, connect(cfg, host, maxOpenConn, maxIdleConn))
+	}
+
+	if len(replicas) == 0 {
+		replicas = append(replicas, connect(cfg, "", maxOpenConn, maxIdleConn))
+	}
+
+	return NewReplicaSet(replicas...)
+}
+
+// Next returns the next healthy replica. When every replica is marked down
+// it still hands one out, a query that may fail beats one that surely does.
+func (rs *ReplicaSet) Next() *DB {
+	count := uint64(len(rs.replicas))
+	start := rs.next.Add(1)
+
+	for i := uint64(0); i < count; i++ {
+		index := (start + i) % count
+		if rs.healthy[index].Load() {
+			return rs.replicas[index]
+		}
+	}
+
+	return rs.replicas[start%count]
+}
+
+// Resize applies the pool sizes of cfg to every replica.
+func (rs *ReplicaSet) Resize(cfg config.Database) {
+	for _, replica := range rs.replicas {
+		replica.Resize(cfg)
+	}
+}
+
+// All returns every replica, healthy or not.
+func (rs *ReplicaSet) All() []*DB {
+	return rs.replicas
+}
+
+// Close closes every replica.
+func (rs *ReplicaSet) Close() error {
+	var errs []error
+
+	for _, replica := range rs.replicas {
+		if err := replica.Close(); err != nil {
+			errs = append(errs, errors.New(replica.Host+": "+err.Error()))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Ping succeeds when at least one replica answers.
+func (rs *ReplicaSet) Ping(ctx context.Context) error {
+	var errs []error
+
+	for _, replica := range rs.replicas {
+		err := replica.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+
+		errs = append(errs, errors.New(replica.Host+": "+err.Error()))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Watch pings every replica each interval and takes failing ones out of
+// the rotation until they answer again.
+func (rs *ReplicaSet) Watch(ctx context.Context, interval time.Duration, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for i, replica := range rs.replicas {
+				pingCtx, cancel := context.WithTimeout(ctx, timeout)
+				err := replica.Ping(pingCtx)
+				cancel()
+
+				wasHealthy := rs.healthy[i].Swap(err == nil)
+
+				if err != nil && wasHealthy {
+					logger.Error(ctx, "replica marked down", logger.Z{"host": replica.Host, "error": err.Error()})
+				} else if err == nil && !wasHealthy {
+					logger.Info(ctx, "replica back in rotation", logger.Z{"host": replica.Host})
+				}
+			}
+		}
+	}
+}
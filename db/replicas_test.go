@@ -0,0 +1,33 @@
+package db
+
+import "testing"
+
+func TestReplicaSet_Next(t *testing.T) {
+	a, b, c := &DB{Host: "a"}, &DB{Host: "b"}, &DB{Host: "c"}
+	rs := NewReplicaSet(a, b, c)
+
+	seen := map[string]int{}
+	for i := 0; i < 6; i++ {
+		seen[rs.Next().Host]++
+	}
+
+	if seen["a"] != 2 || seen["b"] != 2 || seen["c"] != 2 {
+		t.Errorf("round robin is uneven: %v", seen)
+	}
+
+	rs.healthy[1].Store(false)
+
+	for i := 0; i < 6; i++ {
+		if host := rs.Next().Host; host == "b" {
+			t.Errorf("unhealthy replica %s was handed out", host)
+		}
+	}
+
+	for i := range rs.healthy {
+		rs.healthy[i].Store(false)
+	}
+
+	if rs.Next() == nil {
+		t.Errorf("expected a replica even when all are marked down")
+	}
+}
@@ -0,0 +1,67 @@
+package exceptions
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL error numbers, see
+// https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html
+const (
+	errDuplicateEntry     = 1062
+	errRowIsReferenced    = 1451
+	errNoReferencedRow    = 1452
+	errLockWaitTimeout    = 1205
+	errDeadlock           = 1213
+	errQueryInterrupted   = 1317
+	errMaxExecutionTimeUp = 3024
+)
+
+// MapDBError turns the errors of database/sql, the MySQL driver and done
+// contexts into Errors wrapping them, so that errors.Is and errors.As
+// still see the cause. Other errors are returned as they are.
+func MapDBError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return NewNotFound("no results found").Wrap(err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return NewTimeout("request timed out").Wrap(err)
+	case errors.Is(err, context.Canceled):
+		return NewUnavailable("request cancelled").Wrap(err)
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, mysql.ErrInvalidConn), errors.Is(err, sql.ErrConnDone):
+		return NewUnavailable("database unavailable").Wrap(err)
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return err
+	}
+
+	switch mysqlErr.Number {
+	case errDuplicateEntry:
+		return NewConflict("duplicate entry").WithCode("duplicate_entry").Wrap(err)
+	case errRowIsReferenced:
+		return NewConflict("row is still referenced").WithCode("row_referenced").Wrap(err)
+	case errNoReferencedRow:
+		return NewValidation("referenced row does not exist").WithCode("reference_not_found").Wrap(err)
+	case errLockWaitTimeout, errDeadlock:
+		return NewUnavailable("database busy, try again").WithCode("database_busy").Wrap(err)
+	case errQueryInterrupted, errMaxExecutionTimeUp:
+		return NewTimeout("query timed out").Wrap(err)
+	}
+
+	return NewInternal("database error").Wrap(err)
+}
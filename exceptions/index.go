@@ -1 +1,233 @@
 package exceptions
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Kind is the class of an error, it decides the HTTP status it is
+// answered with.
+type Kind int
+
+const (
+	Internal Kind = iota
+	NotFound
+	Validation
+	Conflict
+	Unauthorized
+	Forbidden
+	RateLimited
+	Unavailable
+	Timeout
+	TooLarge
+)
+
+var kinds = map[Kind]struct {
+	status  int
+	code    string
+	message string
+}{
+	Internal:     {http.StatusInternalServerError, "internal", "Some error occurred. Please try again later."},
+	NotFound:     {http.StatusNotFound, "not_found", "No results/resource found"},
+	Validation:   {http.StatusBadRequest, "validation_failed", "Bad Request"},
+	Conflict:     {http.StatusConflict, "conflict", "Conflict"},
+	Unauthorized: {http.StatusUnauthorized, "unauthorized", "Unauthorized"},
+	Forbidden:    {http.StatusForbidden, "forbidden", "Forbidden"},
+	RateLimited:  {http.StatusTooManyRequests, "rate_limited", "Too Many Requests"},
+	Unavailable:  {http.StatusServiceUnavailable, "unavailable", "Service Unavailable"},
+	Timeout:      {http.StatusGatewayTimeout, "timeout", "Request timed out"},
+	TooLarge:     {http.StatusRequestEntityTooLarge, "too_large", "Request Entity Too Large"},
+}
+
+// Status is the HTTP status errors of the kind are answered with.
+func (k Kind) Status() int {
+	return kinds[k].status
+}
+
+// Code is the machine-readable code of errors of the kind that were not
+// given a more specific one.
+func (k Kind) Code() string {
+	return kinds[k].code
+}
+
+// Message is what clients are told in release mode, where the messages of
+// errors are not shown.
+func (k Kind) Message() string {
+	return kinds[k].message
+}
+
+func (k Kind) String() string {
+	return k.Code()
+}
+
+// FieldError describes why one field of the input was rejected.
+type FieldError struct {
+	// Field is the path of the field as the client sent it, e.g.
+	// "Items[0].Code", empty for rules about the input as a whole
+	Field string
+	// Rule is the name of the violated rule, e.g. "required"
+	Rule    string
+	Message string
+}
+
+// Error is an error of the domain, callers branch on Kind and Code and
+// clients get Message.
+type Error struct {
+	Kind Kind
+	// Code is stable, e.g. "transaction_not_found", and defaults to the
+	// code of Kind
+	Code    string
+	Message string
+	Fields  []FieldError
+	// Err is the cause, it is logged but never shown to clients
+	Err error
+}
+
+func newError(kind Kind, message string) *Error {
+	return &Error{Kind: kind, Code: kind.Code(), Message: message}
+}
+
+func NewNotFound(message string) *Error {
+	return newError(NotFound, message)
+}
+
+// NewValidation rejects the input, fields lists the offending fields.
+func NewValidation(message string, fields ...FieldError) *Error {
+	err := newError(Validation, message)
+	err.Fields = fields
+
+	return err
+}
+
+func NewConflict(message string) *Error {
+	return newError(Conflict, message)
+}
+
+func NewUnauthorized(message string) *Error {
+	return newError(Unauthorized, message)
+}
+
+func NewForbidden(message string) *Error {
+	return newError(Forbidden, message)
+}
+
+func NewRateLimited(message string) *Error {
+	return newError(RateLimited, message)
+}
+
+func NewUnavailable(message string) *Error {
+	return newError(Unavailable, message)
+}
+
+func NewTimeout(message string) *Error {
+	return newError(Timeout, message)
+}
+
+func NewTooLarge(message string) *Error {
+	return newError(TooLarge, message)
+}
+
+func NewInternal(message string) *Error {
+	return newError(Internal, message)
+}
+
+// WithCode sets a more specific machine-readable code.
+func (e *Error) WithCode(code string) *Error {
+	e.Code = code
+
+	return e
+}
+
+// Wrap records the cause of e.
+func (e *Error) Wrap(err error) *Error {
+	e.Err = err
+
+	return e
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+
+	return e.Message + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status is the HTTP status e is answered with.
+func (e *Error) Status() int {
+	return e.Kind.Status()
+}
+
+// KindOf returns the kind of the first Error in the chain of err, errors
+// of other types are Internal.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+
+	return Internal
+}
+
+// Is reports whether err is an Error of kind.
+func Is(err error, kind Kind) bool {
+	var e *Error
+
+	return errors.As(err, &e) && e.Kind == kind
+}
+
+// statusError is implemented by errors carrying their own HTTP status,
+// like helpers.Error.
+type statusError interface {
+	error
+	Status() int
+}
+
+// From turns any error into an Error. Errors carrying an HTTP status keep
+// their message and get the kind of the status, database and context
+// errors are mapped with MapDBError and everything else is Internal.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	var withStatus statusError
+	if errors.As(err, &withStatus) {
+		return newError(kindOf(withStatus.Status()), withStatus.Error()).Wrap(err)
+	}
+
+	if mapped := MapDBError(err); errors.As(mapped, &e) {
+		return e
+	}
+
+	return NewInternal(err.Error()).Wrap(err)
+}
+
+func kindOf(status int) Kind {
+	for kind, info := range kinds {
+		if info.status == status {
+			return kind
+		}
+	}
+
+	if status >= http.StatusBadRequest && status < http.StatusInternalServerError {
+		return Validation
+	}
+
+	return Internal
+}
+
+// FromPanic turns a recovered panic value into an Error.
+func FromPanic(r interface{}) *Error {
+	if err, ok := r.(error); ok {
+		return From(err)
+	}
+
+	return NewInternal(fmt.Sprintf("%v", r))
+}
@@ -0,0 +1,68 @@
+package exceptions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type statusErr struct{ status int }
+
+func (e statusErr) Error() string { return "with status" }
+func (e statusErr) Status() int   { return e.status }
+
+func TestMapDBError(t *testing.T) {
+	cases := []struct {
+		err  error
+		kind Kind
+		code string
+	}{
+		{sql.ErrNoRows, NotFound, "not_found"},
+		{fmt.Errorf("insert: %w", &mysql.MySQLError{Number: 1062}), Conflict, "duplicate_entry"},
+		{&mysql.MySQLError{Number: 1213}, Unavailable, "database_busy"},
+		{&mysql.MySQLError{Number: 1452}, Validation, "reference_not_found"},
+		{&mysql.MySQLError{Number: 1146}, Internal, "internal"},
+		{context.DeadlineExceeded, Timeout, "timeout"},
+	}
+
+	for _, tc := range cases {
+		mapped := MapDBError(tc.err)
+
+		if !Is(mapped, tc.kind) || From(mapped).Code != tc.code {
+			t.Errorf("%v: got %v %q, want %v %q", tc.err, KindOf(mapped), From(mapped).Code, tc.kind, tc.code)
+		}
+
+		if !errors.Is(mapped, tc.err) {
+			t.Errorf("%v: cause lost", tc.err)
+		}
+	}
+
+	plain := errors.New("plain")
+	if MapDBError(plain) != plain || MapDBError(nil) != nil {
+		t.Error("errors that are not database errors should be returned as they are")
+	}
+}
+
+func TestFrom(t *testing.T) {
+	notFound := NewNotFound("transaction 1 not found").WithCode("transaction_not_found")
+	if got := From(fmt.Errorf("get: %w", notFound)); got != notFound {
+		t.Errorf("wrapped Error not found: %v", got)
+	}
+
+	if got := From(statusErr{http.StatusTooManyRequests}); got.Kind != RateLimited || got.Message != "with status" {
+		t.Errorf("status error: got %v %q", got.Kind, got.Message)
+	}
+
+	if got := From(statusErr{http.StatusTeapot}); got.Status() != http.StatusBadRequest {
+		t.Errorf("unknown 4xx: got %d", got.Status())
+	}
+
+	if got := FromPanic("boom"); got.Kind != Internal || got.Message != "boom" {
+		t.Errorf("panic: got %v %q", got.Kind, got.Message)
+	}
+}
@@ -0,0 +1,102 @@
+package helpers
+
+import (
+	"reflect"
+	"strings"
+
+	"restapi/exceptions"
+)
+
+// Fieldset is the set of view fields a client asked for with ?fields=,
+// empty when it wants them all.
+type Fieldset []string
+
+// ParseFields reads a comma separated ?fields= value against the JSON
+// names of the fields of view, a struct or a pointer to one.
+func ParseFields(query string, view interface{}) (Fieldset, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+
+	known := jsonFields(reflect.TypeOf(view))
+
+	var (
+		fields     Fieldset
+		violations []exceptions.FieldError
+	)
+
+	for _, name := range strings.Split(query, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		if _, ok := known[name]; !ok {
+			violations = append(violations, exceptions.FieldError{
+				Field:   "fields",
+				Rule:    "oneof",
+				Message: "unknown field " + name,
+			})
+
+			continue
+		}
+
+		fields = append(fields, name)
+	}
+
+	if len(violations) > 0 {
+		return nil, exceptions.NewValidation("invalid fields", violations...)
+	}
+
+	return fields, nil
+}
+
+// Select returns view itself when fs is empty, otherwise a map holding
+// only the fields in fs.
+func (fs Fieldset) Select(view interface{}) interface{} {
+	if len(fs) == 0 {
+		return view
+	}
+
+	value := reflect.Indirect(reflect.ValueOf(view))
+	known := jsonFields(value.Type())
+
+	selected := make(map[string]interface{}, len(fs))
+	for _, name := range fs {
+		if index, ok := known[name]; ok {
+			selected[name] = value.Field(index).Interface()
+		}
+	}
+
+	return selected
+}
+
+// jsonFields maps the JSON names of the exported fields of t to their
+// index.
+func jsonFields(t reflect.Type) map[string]int {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	fields := make(map[string]int, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		fields[name] = i
+	}
+
+	return fields
+}
@@ -0,0 +1,40 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+
+	"restapi/exceptions"
+)
+
+type view struct {
+	ID     int    `json:"Id"`
+	Name   string `json:"Name,omitempty"`
+	Hidden string `json:"-"`
+	Plain  bool
+}
+
+func TestFieldset(t *testing.T) {
+	fields, err := ParseFields(" Id, Plain ", view{})
+	if err != nil {
+		t.Fatalf("ParseFields: %s", err)
+	}
+
+	got := fields.Select(view{ID: 1, Name: "n", Plain: true})
+	want := map[string]interface{}{"Id": 1, "Plain": true}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if all, _ := ParseFields("", view{}); all.Select(view{ID: 2}) != (view{ID: 2}) {
+		t.Error("an empty fieldset should keep the view")
+	}
+
+	_, err = ParseFields("Id,Hidden,Nope", &view{})
+
+	e := exceptions.From(err)
+	if e.Kind != exceptions.Validation || len(e.Fields) != 2 {
+		t.Errorf("unknown fields not reported: %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package helpers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+type Pagination struct {
+	NextCursor string `json:"NextCursor,omitempty"`
+	HasMore    bool   `json:"HasMore"`
+	Limit      int    `json:"Limit"`
+}
+
+// EncodeCursor turns a cursor struct into an opaque url safe token.
+func EncodeCursor(cursor interface{}) (string, error) {
+	raw, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// DecodeCursor is the inverse of EncodeCursor.
+func DecodeCursor(token string, cursor interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, cursor)
+}
@@ -0,0 +1,34 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	type cursor struct {
+		SortBy string      `json:"s"`
+		Value  interface{} `json:"v"`
+		ID     int32       `json:"id"`
+	}
+
+	want := cursor{SortBy: "code", Value: "ab_c%", ID: 42}
+
+	token, err := EncodeCursor(want)
+	if err != nil {
+		t.Fatalf("EncodeCursor: %s", err)
+	}
+
+	var got cursor
+	if err := DecodeCursor(token, &got); err != nil {
+		t.Fatalf("DecodeCursor: %s", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got: %v\n Want: %v", got, want)
+	}
+
+	if err := DecodeCursor("not a cursor!", &got); err == nil {
+		t.Errorf("expected an error for a malformed cursor")
+	}
+}
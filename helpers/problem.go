@@ -0,0 +1,68 @@
+package helpers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MIMEProblemJSON is the media type of RFC 7807 error bodies.
+const MIMEProblemJSON = "application/problem+json"
+
+// ProblemTypeBase prefixes the error code to form the type of a Problem.
+var ProblemTypeBase = "urn:restapi:problem:"
+
+// Problem is an RFC 7807 error body, sent instead of Error to clients
+// that accept application/problem+json.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Code and Errors are extension members
+	Code   string         `json:"code,omitempty"`
+	Errors []ProblemField `json:"errors,omitempty"`
+}
+
+// ProblemField is one rejected field of a Problem.
+type ProblemField struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message"`
+}
+
+// NewProblem describes err, already masked for release mode, as a
+// Problem about the request at instance.
+func NewProblem(err Error, instance string) Problem {
+	problem := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(err.Code),
+		Status:   err.Code,
+		Detail:   err.Message,
+		Instance: instance,
+		Code:     err.ErrorCode,
+	}
+
+	if err.ErrorCode != "" {
+		problem.Type = ProblemTypeBase + err.ErrorCode
+	}
+
+	for _, field := range err.Fields {
+		problem.Errors = append(problem.Errors, ProblemField{Field: field.Field, Rule: field.Rule, Message: field.Message})
+	}
+
+	return problem
+}
+
+// WantsProblem reports whether the client prefers problem+json bodies
+// over the Error envelope.
+func WantsProblem(c *gin.Context) bool {
+	return c.NegotiateFormat(gin.MIMEJSON, MIMEProblemJSON) == MIMEProblemJSON
+}
+
+func abortWithProblem(c *gin.Context, problem Problem) {
+	// the JSON renderer of gin keeps a content type that is already set
+	c.Header("Content-Type", MIMEProblemJSON)
+	c.AbortWithStatusJSON(problem.Status, problem)
+}
@@ -0,0 +1,97 @@
+package helpers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"restapi/exceptions"
+
+	"github.com/gin-gonic/gin"
+)
+
+func abort(t *testing.T, accept string, err error) *httptest.ResponseRecorder {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/transaction/7", nil)
+	c.Request.Header.Set("Accept", accept)
+
+	AbortWithError(c, err)
+
+	return recorder
+}
+
+func TestAbortWithError_Negotiation(t *testing.T) {
+	err := exceptions.NewValidation("invalid query",
+		exceptions.FieldError{Field: "limit", Message: "must be a positive integer"})
+
+	recorder := abort(t, "application/json", err)
+	if got := recorder.Header().Get("Content-Type"); got != "application/json; charset=utf-8" {
+		t.Errorf("envelope content type: %q", got)
+	}
+
+	var envelope Error
+	if err := json.Unmarshal(recorder.Body.Bytes(), &envelope); err != nil {
+		t.Fatal(err)
+	}
+
+	if envelope.Code != http.StatusBadRequest || envelope.ErrorCode != "validation_failed" || len(envelope.Fields) != 1 {
+		t.Errorf("envelope: %+v", envelope)
+	}
+
+	recorder = abort(t, "application/problem+json", err)
+	if got := recorder.Header().Get("Content-Type"); got != MIMEProblemJSON {
+		t.Errorf("problem content type: %q", got)
+	}
+
+	var problem Problem
+	if err := json.Unmarshal(recorder.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Problem{
+		Type:     ProblemTypeBase + "validation_failed",
+		Title:    "Bad Request",
+		Status:   http.StatusBadRequest,
+		Detail:   "invalid query",
+		Instance: "/api/v1/transaction/7",
+		Code:     "validation_failed",
+		Errors:   []ProblemField{{Field: "limit", Message: "must be a positive integer"}},
+	}
+
+	if problem.Type != want.Type || problem.Title != want.Title || problem.Status != want.Status ||
+		problem.Detail != want.Detail || problem.Instance != want.Instance || len(problem.Errors) != 1 ||
+		problem.Errors[0] != want.Errors[0] {
+		t.Errorf("got %+v\nwant %+v", problem, want)
+	}
+}
+
+func TestAbortWithError_ReleaseMasking(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	defer gin.SetMode(gin.TestMode)
+
+	var problem Problem
+
+	recorder := abort(t, "application/problem+json", exceptions.NewInternal("dial tcp 10.0.0.1:3306: refused"))
+	if err := json.Unmarshal(recorder.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+
+	if problem.Detail != exceptions.Internal.Message() || problem.Status != http.StatusInternalServerError {
+		t.Errorf("problem not masked: %+v", problem)
+	}
+
+	var envelope Error
+
+	recorder = abort(t, "", exceptions.NewNotFound("transaction 7 not found"))
+	if err := json.Unmarshal(recorder.Body.Bytes(), &envelope); err != nil {
+		t.Fatal(err)
+	}
+
+	if envelope.Message != exceptions.NotFound.Message() {
+		t.Errorf("envelope not masked: %+v", envelope)
+	}
+}
@@ -60,6 +60,8 @@ func PrepareIntegerInClauseQuery(data []int) (*QueryClause, error) {
 	return &qc, nil
 }
 
+// Deprecated: ComputeExpression truncates the result to an int and knows
+// no variables. Evaluate expressions with rules.Engine instead.
 func ComputeExpression(expression string) (int, error) {
 	evalExpression, err := govaluate.NewEvaluableExpression(expression)
 	if err != nil {
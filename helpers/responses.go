@@ -2,23 +2,23 @@ package helpers
 
 import (
 	"flag"
-	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"path"
-	"path/filepath"
-	"restapi/logger"
-	"runtime"
 	"strings"
 
+	"restapi/config"
+	"restapi/exceptions"
+	"restapi/logger"
+
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
 
 type Response struct {
-	Data   interface{} `json:"Data,omitempty"`
-	Errors []Error     `json:"Errors,omitempty"`
+	Data       interface{} `json:"Data,omitempty"`
+	Errors     []Error     `json:"Errors,omitempty"`
+	Pagination *Pagination `json:"Pagination,omitempty"`
 }
 
 func NewResponse(data interface{}, errors []Error) Response {
@@ -33,9 +33,39 @@ func NewResponse(data interface{}, errors []Error) Response {
 	}
 }
 
+func NewPaginatedResponse(data interface{}, pagination Pagination) Response {
+	response := NewResponse(data, nil)
+	response.Pagination = &pagination
+
+	return response
+}
+
 type Error struct {
 	Message string `json:"Message,omitempty"`
-	Code    int    `json:"Code,omitempty"`
+	// Code is the HTTP status
+	Code int `json:"Code,omitempty"`
+	// ErrorCode is the machine-readable code of exceptions.Error
+	ErrorCode string `json:"ErrorCode,omitempty"`
+	// Field and Rule locate a violation listed in Fields
+	Field  string  `json:"Field,omitempty"`
+	Rule   string  `json:"Rule,omitempty"`
+	Fields []Error `json:"Fields,omitempty"`
+}
+
+// FieldErrors lists the violations of fields as Errors.
+func FieldErrors(fields []exceptions.FieldError) []Error {
+	var errs []Error
+
+	for _, field := range fields {
+		errs = append(errs, Error{
+			Code:    http.StatusBadRequest,
+			Message: field.Message,
+			Field:   field.Field,
+			Rule:    field.Rule,
+		})
+	}
+
+	return errs
 }
 
 func ValidationError(message string) Error {
@@ -50,6 +80,24 @@ func NotFoundError(message string) Error {
 	return Error{Code: http.StatusNotFound, Message: message}
 }
 
+func UnauthorizedError(message string) Error {
+	return Error{Code: http.StatusUnauthorized, Message: message}
+}
+
+func ForbiddenError(message string) Error {
+	return Error{Code: http.StatusForbidden, Message: message}
+}
+
+func TooManyRequestsError(message string) Error {
+	return Error{Code: http.StatusTooManyRequests, Message: message}
+}
+
+func GatewayTimeoutError(message string) Error {
+	return Error{Code: http.StatusGatewayTimeout, Message: message}
+}
+
+// Deprecated: a 204 carries no body to explain an error, so errors built
+// with NoContentError are answered as a 500. Answer with c.Status instead.
 func NoContentError(message string) Error {
 	return Error{Code: http.StatusNoContent, Message: message}
 }
@@ -62,56 +110,56 @@ func (err Error) Status() int {
 	return err.Code
 }
 
+// Recover answers a panicking handler with the error it panicked with,
+// see AbortWithError.
 func Recover(c *gin.Context, apiPath string) {
 	if r := recover(); r != nil {
-		err, valid := r.(Error)
+		abortWithError(c, exceptions.FromPanic(r), apiPath)
+	}
+}
 
-		if !valid {
-			err = InternalServerError(fmt.Sprintf("%v", r))
-		}
+// AbortWithError answers the request with err, mapped by exceptions.From,
+// as an Error or, when the client asks for it, a Problem. In release mode
+// clients only get the generic message of the error kind, and nothing but
+// the status of internal errors.
+func AbortWithError(c *gin.Context, err error) {
+	abortWithError(c, exceptions.From(err), c.FullPath())
+}
+
+func abortWithError(c *gin.Context, err *exceptions.Error, apiPath string) {
+	logger.Error(c, err.Error(), logger.Z{"errCode": err.Status(), "code": err.Code, "apiPath": apiPath})
+
+	response := Error{
+		Code:      err.Status(),
+		Message:   err.Message,
+		ErrorCode: err.Code,
+		Fields:    FieldErrors(err.Fields),
+	}
+
+	if gin.Mode() == gin.ReleaseMode {
+		response.Message = err.Kind.Message()
 
-		logger.Error(c, err.Message, logger.Z{"errCode": err.Code, "apiPath": apiPath})
-
-		if os.Getenv("GIN_MODE") == "release" {
-			switch err.Code {
-			case http.StatusNotFound:
-				{
-					err = NotFoundError("No results/resource found")
-
-					break
-				}
-			case http.StatusBadRequest:
-				{
-					err = ValidationError("Bad Request")
-
-					break
-				}
-			case http.StatusNoContent:
-				{
-					err = NoContentError("No Content")
-
-					break
-				}
-			case http.StatusInternalServerError:
-				fallthrough
-			default:
-				{
-					err = InternalServerError("Some error occurred. Please try again later.")
-
-					break
-				}
-			}
+		if err.Kind == exceptions.Internal {
+			response.ErrorCode, response.Fields = err.Kind.Code(), nil
 		}
+	}
+
+	// if we are here, we should override any
+	// previous headers set, just in case
+	c.Header("Cache-Control", "no-store, no-cache, must-revalidate, max-age=0")
+
+	if WantsProblem(c) {
+		abortWithProblem(c, NewProblem(response, c.Request.URL.Path))
 
-		// if we are here, we should override any
-		// previous headers set, just in case
-		c.Header("Cache-Control", "no-store, no-cache, must-revalidate, max-age=0")
-		c.AbortWithStatusJSON(err.Code, err)
+		return
 	}
+
+	c.AbortWithStatusJSON(response.Code, response)
 }
 
+// Deprecated: InitiateLoggerAndLoadEnv parses the -e flag itself, new
+// binaries should be sub commands of cmd/app.go and call LoadEnvAndInitLogger.
 func InitiateLoggerAndLoadEnv(logFileName string) string {
-	logFileName = strings.ToLower(logFileName)
 	environment := flag.String("e", "development", "")
 
 	flag.Usage = func() {
@@ -121,17 +169,32 @@ func InitiateLoggerAndLoadEnv(logFileName string) string {
 
 	flag.Parse()
 
-	envFile := "." + *environment + ".env"
-
-	_, b, _, _ := runtime.Caller(0)
-	basepath := filepath.Dir(b)
-	ap := path.Join(basepath, "../config", envFile)
+	return LoadEnvAndInitLogger(logFileName, *environment)
+}
 
-	if err := godotenv.Load(ap); err != nil {
+// LoadEnvAndInitLogger loads config/.{environment}.env into the process
+// environment and initialises the logger writing to {logFileName}.log. It
+// returns the env file name.
+func LoadEnvAndInitLogger(logFileName string, environment string) string {
+	cfg, err := config.Load(environment)
+	if err != nil {
 		log.Fatalf("%s", err)
 	}
 
-	logger.Init(logFileName, os.Getenv("LOG_LEVEL"))
+	// binaries built on this read their settings with os.Getenv
+	if envFile := config.EnvFile(environment); fileExists(envFile) {
+		if err := godotenv.Load(envFile); err != nil {
+			log.Fatalf("%s", err)
+		}
+	}
+
+	logger.Configure(strings.ToLower(logFileName), cfg.Log)
+
+	return "." + cfg.Environment + ".env"
+}
+
+func fileExists(file string) bool {
+	_, err := os.Stat(file)
 
-	return envFile
+	return err == nil
 }
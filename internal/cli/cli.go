@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// exit codes
+const (
+	ExitOK    = 0
+	ExitError = 1
+	ExitUsage = 2
+)
+
+// ErrUsage makes Main print the command help and exit with ExitUsage.
+var ErrUsage = errors.New("invalid usage")
+
+// Command is one sub command of the binary.
+type Command struct {
+	Name    string
+	Summary string
+	Usage   string
+	// Flags declares the command flags, it may be nil
+	Flags func(flags *flag.FlagSet)
+	Run   func(flags *flag.FlagSet, out io.Writer) error
+}
+
+func commands() []*Command {
+	return []*Command{
+		serveCommand(),
+		consumeCommand(),
+		relayCommand(),
+		dlqReplayCommand(),
+		migrateCommand(),
+		configCommand(),
+		encryptSecretCommand(),
+	}
+}
+
+// Main runs the sub command named by args[0] and returns the exit code.
+// Invocations without a sub command, like "app -e test", start the server
+// as they always did.
+func Main(args []string) int {
+	return run(args, os.Stdout, os.Stderr)
+}
+
+func run(args []string, out io.Writer, errOut io.Writer) int {
+	if len(args) == 0 || (strings.HasPrefix(args[0], "-") && !isHelp(args[0])) {
+		args = append([]string{"serve"}, args...)
+	}
+
+	if isHelp(args[0]) {
+		if len(args) > 1 {
+			if command := find(args[1]); command != nil {
+				newFlagSet(command, out).Usage()
+
+				return ExitOK
+			}
+		}
+
+		printUsage(out)
+
+		return ExitOK
+	}
+
+	command := find(args[0])
+	if command == nil {
+		fmt.Fprintf(errOut, "unknown command %q\n\n", args[0])
+		printUsage(errOut)
+
+		return ExitUsage
+	}
+
+	flags := newFlagSet(command, errOut)
+
+	if err := flags.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+
+		return ExitUsage
+	}
+
+	if err := command.Run(flags, out); err != nil {
+		if errors.Is(err, ErrUsage) {
+			flags.Usage()
+
+			return ExitUsage
+		}
+
+		fmt.Fprintf(errOut, "%s: %s\n", command.Name, err)
+
+		return ExitError
+	}
+
+	return ExitOK
+}
+
+func isHelp(arg string) bool {
+	return arg == "help" || arg == "-h" || arg == "-help" || arg == "--help"
+}
+
+func find(name string) *Command {
+	for _, command := range commands() {
+		if command.Name == name {
+			return command
+		}
+	}
+
+	return nil
+}
+
+func newFlagSet(command *Command, out io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(command.Name, flag.ContinueOnError)
+	flags.SetOutput(out)
+
+	if command.Flags != nil {
+		command.Flags(flags)
+	}
+
+	flags.Usage = func() {
+		fmt.Fprintf(out, "Usage: app %s %s\n\n%s\n", command.Name, strings.TrimSpace(command.Usage), command.Summary)
+
+		hasFlags := false
+		flags.VisitAll(func(*flag.Flag) { hasFlags = true })
+
+		if hasFlags {
+			fmt.Fprintln(out, "\nFlags:")
+			flags.PrintDefaults()
+		}
+	}
+
+	return flags
+}
+
+func printUsage(out io.Writer) {
+	fmt.Fprintln(out, "Usage: app <command> [flags]")
+	fmt.Fprintln(out, "\nCommands:")
+
+	for _, command := range commands() {
+		fmt.Fprintf(out, "  %-16s %s\n", command.Name, command.Summary)
+	}
+
+	fmt.Fprintln(out, "\nRun \"app help <command>\" for the flags of a command.")
+}
+
+// environmentFlag is shared by every command that reads an env file.
+func environmentFlag(flags *flag.FlagSet) {
+	flags.String("e", "development", "environment, selects config/.{env}.env")
+}
+
+func environment(flags *flag.FlagSet) string {
+	return flags.Lookup("e").Value.String()
+}
@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"restapi/config"
+)
+
+func configCommand() *Command {
+	return &Command{
+		Name:    "config",
+		Summary: "print the effective configuration with secrets redacted",
+		Usage:   "[-e env] print",
+		Flags:   environmentFlag,
+		Run: func(flags *flag.FlagSet, out io.Writer) error {
+			if flags.NArg() != 1 || flags.Arg(0) != "print" {
+				return ErrUsage
+			}
+
+			cfg, err := config.Load(environment(flags))
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(out, "# environment %s, read from %s and the process environment\n", cfg.Environment, strings.Join(cfg.Sources, ", "))
+
+			settings := cfg.Settings()
+
+			for _, name := range settings.Names() {
+				fmt.Fprintf(out, "%s=%s\n", name, config.Redact(name, settings[name]))
+			}
+
+			return nil
+		},
+	}
+}
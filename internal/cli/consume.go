@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"restapi/config"
+	"restapi/kafka"
+	"restapi/logger"
+
+	"github.com/IBM/sarama"
+)
+
+func consumeCommand() *Command {
+	return &Command{
+		Name:    "consume",
+		Summary: "run a Kafka consumer group with a registered processor",
+		Usage:   "[-e env] [-prefix PREFIX] [-topics a,b] [-attempts n] [-retry-tiers 1m,10m] [-dlq] -processor name",
+		Flags: func(flags *flag.FlagSet) {
+			environmentFlag(flags)
+			flags.String("prefix", "", "env prefix of the {PREFIX}_KAFKA_* settings, defaults to KAFKA_PREFIX")
+			flags.String("topics", "", "comma separated topics, defaults to {PREFIX}_KAFKA_TOPIC")
+			flags.String("processor", "", "processor to run, one of: "+strings.Join(kafka.ProcessorNames(), ", "))
+			flags.Int("attempts", 1, "times a message is processed before it is forwarded or skipped")
+			flags.Duration("backoff", time.Second, "wait before the first in-process retry, doubled for each next one")
+			flags.Duration("max-backoff", 30*time.Second, "longest wait between in-process retries")
+			flags.String("retry-tiers", "", "comma separated delays of the retry topics, e.g. 1m,10m for {topic}.retry.1m and {topic}.retry.10m")
+			flags.Bool("dlq", false, "forward messages that failed every retry to {topic}.dlq")
+		},
+		Run: func(flags *flag.FlagSet, _ io.Writer) error {
+			name := flags.Lookup("processor").Value.String()
+			if name == "" || flags.NArg() > 0 {
+				return ErrUsage
+			}
+
+			processor, ok := kafka.LookupProcessor(name)
+			if !ok {
+				return fmt.Errorf("unknown processor %q, registered processors are: %s", name, strings.Join(kafka.ProcessorNames(), ", "))
+			}
+
+			cfg, err := config.Load(environment(flags))
+			if err != nil {
+				return err
+			}
+
+			prefix := flags.Lookup("prefix").Value.String()
+			if prefix == "" {
+				prefix = cfg.Value("KAFKA_PREFIX")
+			}
+
+			if prefix == "" {
+				return errors.New("no -prefix given and KAFKA_PREFIX is not set")
+			}
+
+			cluster := cfg.KafkaCluster(prefix)
+
+			topics := flags.Lookup("topics").Value.String()
+			if topics == "" {
+				topics = cluster.Topic
+			}
+
+			if topics == "" {
+				err = errors.Join(err, fmt.Errorf("no topics given and %s_KAFKA_TOPIC is not set", prefix))
+			}
+
+			if cluster.Group == "" {
+				err = errors.Join(err, fmt.Errorf("%s_KAFKA_GROUP is required", prefix))
+			}
+
+			retry, retryErr := retryPolicy(flags)
+
+			if err := errors.Join(cluster.Validate(), err, retryErr); err != nil {
+				return err
+			}
+
+			logger.Configure("consumer-"+name, cfg.Log)
+
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			if retry != nil && (len(retry.Tiers) > 0 || retry.DLQ) {
+				retry.Producer, err = newForwardingProducer(cluster)
+				if err != nil {
+					return err
+				}
+				defer retry.Producer.Close()
+			}
+
+			group := kafka.NewConsumerGroupFromConfig(cluster, kafka.Params{})
+			defer group.Client.Close()
+
+			group.Retry = retry
+
+			return group.Consume(ctx, strings.Split(topics, ","), processor)
+		},
+	}
+}
+
+// retryPolicy reads the retry flags, it is nil when failed messages are
+// to be skipped right away.
+func retryPolicy(flags *flag.FlagSet) (*kafka.RetryPolicy, error) {
+	policy := &kafka.RetryPolicy{
+		Attempts:   flags.Lookup("attempts").Value.(flag.Getter).Get().(int),
+		Backoff:    flags.Lookup("backoff").Value.(flag.Getter).Get().(time.Duration),
+		MaxBackoff: flags.Lookup("max-backoff").Value.(flag.Getter).Get().(time.Duration),
+		DLQ:        flags.Lookup("dlq").Value.(flag.Getter).Get().(bool),
+	}
+
+	if policy.Attempts < 1 {
+		return nil, errors.New("-attempts must be at least 1")
+	}
+
+	if tiers := flags.Lookup("retry-tiers").Value.String(); tiers != "" {
+		for _, tier := range strings.Split(tiers, ",") {
+			delay, err := time.ParseDuration(strings.TrimSpace(tier))
+			if err != nil || delay <= 0 {
+				return nil, fmt.Errorf("-retry-tiers: %q is not a positive duration", tier)
+			}
+
+			policy.Tiers = append(policy.Tiers, delay)
+		}
+	}
+
+	if policy.Attempts == 1 && len(policy.Tiers) == 0 && !policy.DLQ {
+		return nil, nil
+	}
+
+	return policy, nil
+}
+
+// newForwardingProducer sends to retry topics and dead-letter queues,
+// keeping the partitioning by key of the original topics.
+func newForwardingProducer(cluster config.Kafka) (*kafka.Producer, error) {
+	return kafka.NewProducerFromConfig(cluster, kafka.ConfigParams{
+		Partitioner:  sarama.NewHashPartitioner,
+		RequiredAcks: sarama.WaitForAll,
+	})
+}
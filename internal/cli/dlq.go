@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"restapi/config"
+	"restapi/kafka"
+	"restapi/logger"
+
+	"github.com/IBM/sarama"
+)
+
+func dlqReplayCommand() *Command {
+	return &Command{
+		Name:    "dlq-replay",
+		Summary: "republish dead-letter queue messages to the topics they failed on",
+		Usage:   "[-e env] [-prefix PREFIX] [-topics a.dlq,b.dlq] [-limit n]",
+		Flags: func(flags *flag.FlagSet) {
+			environmentFlag(flags)
+			flags.String("prefix", "", "env prefix of the {PREFIX}_KAFKA_* settings, defaults to KAFKA_PREFIX")
+			flags.String("topics", "", "comma separated dead-letter queues, defaults to {PREFIX}_KAFKA_TOPIC.dlq")
+			flags.Int("limit", 0, "most messages to replay, 0 replays all of them")
+		},
+		Run: func(flags *flag.FlagSet, out io.Writer) error {
+			if flags.NArg() > 0 {
+				return ErrUsage
+			}
+
+			limit := flags.Lookup("limit").Value.(flag.Getter).Get().(int)
+			if limit < 0 {
+				return ErrUsage
+			}
+
+			cfg, err := config.Load(environment(flags))
+			if err != nil {
+				return err
+			}
+
+			prefix := flags.Lookup("prefix").Value.String()
+			if prefix == "" {
+				prefix = cfg.Value("KAFKA_PREFIX")
+			}
+
+			if prefix == "" {
+				return errors.New("no -prefix given and KAFKA_PREFIX is not set")
+			}
+
+			cluster := cfg.KafkaCluster(prefix)
+
+			topics := flags.Lookup("topics").Value.String()
+			if topics == "" && cluster.Topic != "" {
+				topics = kafka.DLQTopic(cluster.Topic)
+			}
+
+			if topics == "" {
+				err = errors.Join(err, fmt.Errorf("no topics given and %s_KAFKA_TOPIC is not set", prefix))
+			}
+
+			if cluster.Group == "" {
+				err = errors.Join(err, fmt.Errorf("%s_KAFKA_GROUP is required", prefix))
+			}
+
+			if err := errors.Join(cluster.Validate(), err); err != nil {
+				return err
+			}
+
+			logger.Configure("dlq-replay", cfg.Log)
+
+			producer, err := newForwardingProducer(cluster)
+			if err != nil {
+				return err
+			}
+			defer producer.Close()
+
+			// a group of its own, so that the replay position is kept apart
+			// from any consumer of the queues
+			cluster.Group += ".dlq-replay"
+
+			group := kafka.NewConsumerGroupFromConfig(cluster, kafka.Params{OffsetInitial: sarama.OffsetOldest})
+			defer group.Client.Close()
+
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			replayed, err := group.ReplayDLQ(ctx, strings.Split(topics, ","), producer, limit)
+
+			fmt.Fprintf(out, "replayed %d messages\n", replayed)
+
+			return err
+		},
+	}
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"restapi/config"
+	"restapi/util"
+)
+
+func encryptSecretCommand() *Command {
+	return &Command{
+		Name:    "encrypt-secret",
+		Summary: "encrypt a value for {PREFIX}DBENCRYPTEDPASSWORD and similar settings",
+		Usage:   "[-e env] [-key-env NAME] [value]\n\nThe value is read from stdin when it is not given, which keeps it out of the shell history.",
+		Flags: func(flags *flag.FlagSet) {
+			environmentFlag(flags)
+			flags.String("key-env", "DB_ENCRYPTION_SECRET_KEY", "setting that holds the encryption key")
+		},
+		Run: func(flags *flag.FlagSet, out io.Writer) error {
+			if flags.NArg() > 1 {
+				return ErrUsage
+			}
+
+			keyName := flags.Lookup("key-env").Value.String()
+
+			cfg, err := config.Load(environment(flags))
+			if err != nil {
+				return err
+			}
+
+			key := cfg.Value(keyName)
+			if key == "" {
+				return fmt.Errorf("%s is not set", keyName)
+			}
+
+			value := flags.Arg(0)
+			if flags.NArg() == 0 {
+				line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+				if err != nil && !errors.Is(err, io.EOF) {
+					return err
+				}
+
+				value = strings.TrimRight(line, "\r\n")
+			}
+
+			if value == "" {
+				return errors.New("nothing to encrypt")
+			}
+
+			encrypted := util.EncryptWithRandomIV([]byte(key), []byte(value))
+			if encrypted == "" {
+				return errors.New("encryption failed")
+			}
+
+			// make sure the running service will be able to read it back
+			decrypted, err := util.DecryptWithRandomIV([]byte(key), encrypted)
+			if err != nil || !bytes.Equal(decrypted, []byte(value)) {
+				return errors.New("encrypted value does not decrypt to the input")
+			}
+
+			fmt.Fprintln(out, encrypted)
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"io"
+
+	"restapi/config"
+	"restapi/db/migrations"
+)
+
+func migrateCommand() *Command {
+	return &Command{
+		Name:    "migrate",
+		Summary: "apply, revert or create schema migrations",
+		Usage:   "[-e env] [-prefix MASTER] [-dir path] <command>\n\n" + migrations.Usage,
+		Flags: func(flags *flag.FlagSet) {
+			environmentFlag(flags)
+			flags.String("prefix", "MASTER", "env prefix of the database to migrate")
+			flags.String("dir", "", "read migrations from this directory instead of the embedded ones")
+		},
+		Run: func(flags *flag.FlagSet, out io.Writer) error {
+			cfg, err := config.Load(environment(flags))
+			if err != nil {
+				return err
+			}
+
+			err = migrations.Run(migrations.Options{
+				Database:  cfg.Database(flags.Lookup("prefix").Value.String()),
+				Dir:       flags.Lookup("dir").Value.String(),
+				SourceDir: cfg.Migrations.Dir,
+			}, flags.Args(), out)
+
+			if errors.Is(err, migrations.ErrUsage) {
+				return ErrUsage
+			}
+
+			return err
+		},
+	}
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"os/signal"
+	"syscall"
+
+	"restapi/config"
+	"restapi/db"
+	"restapi/internal/service/outbox"
+	"restapi/logger"
+)
+
+const (
+	relayMaxOpenConn = 2
+	relayMaxIdleConn = 1
+)
+
+func relayCommand() *Command {
+	return &Command{
+		Name:    "relay",
+		Summary: "publish the outbox table to Kafka",
+		Usage:   "[-e env] [-prefix PREFIX]",
+		Flags: func(flags *flag.FlagSet) {
+			environmentFlag(flags)
+			flags.String("prefix", "", "env prefix of the {PREFIX}_KAFKA_* settings, defaults to KAFKA_PREFIX")
+		},
+		Run: func(flags *flag.FlagSet, _ io.Writer) error {
+			if flags.NArg() > 0 {
+				return ErrUsage
+			}
+
+			cfg, err := config.Load(environment(flags))
+			if err != nil {
+				return err
+			}
+
+			prefix := flags.Lookup("prefix").Value.String()
+			if prefix == "" {
+				prefix = cfg.Value("KAFKA_PREFIX")
+			}
+
+			if prefix == "" {
+				return errors.New("no -prefix given and KAFKA_PREFIX is not set")
+			}
+
+			cluster := cfg.KafkaCluster(prefix)
+
+			if err := errors.Join(cfg.Master.Validate(), cfg.Outbox.Validate(), cluster.Validate()); err != nil {
+				return err
+			}
+
+			logger.Configure("outbox-relay", cfg.Log)
+
+			// messages of a key must land on one partition to stay ordered
+			producer, err := newForwardingProducer(cluster)
+			if err != nil {
+				return err
+			}
+			defer producer.Close()
+
+			masterDB := db.Connect(cfg.Master, relayMaxOpenConn, relayMaxIdleConn)
+			defer masterDB.Close()
+
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			outbox.NewRelay(db.NewReplicaSet(masterDB), masterDB, producer, cfg.Outbox).Run(ctx)
+
+			return nil
+		},
+	}
+}
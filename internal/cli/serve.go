@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"flag"
+	"io"
+
+	"restapi/internal/server"
+)
+
+func serveCommand() *Command {
+	return &Command{
+		Name:    "serve",
+		Summary: "start the HTTP server",
+		Usage:   "[-e env]",
+		Flags:   environmentFlag,
+		Run: func(flags *flag.FlagSet, _ io.Writer) error {
+			if flags.NArg() > 0 {
+				return ErrUsage
+			}
+
+			server.Init("." + environment(flags) + ".env")
+
+			return nil
+		},
+	}
+}
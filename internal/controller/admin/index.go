@@ -0,0 +1,24 @@
+package admin
+
+import (
+	"context"
+
+	"restapi/config"
+)
+
+// Reloader applies a fresh configuration to the running server.
+type Reloader interface {
+	Reload(ctx context.Context) ([]config.Change, error)
+}
+
+type Controller struct {
+	reloader Reloader
+}
+
+func NewAdminController(reloader Reloader) *Controller {
+	if reloader == nil {
+		panic("reloader cannot be null")
+	}
+
+	return &Controller{reloader: reloader}
+}
@@ -0,0 +1,29 @@
+package admin
+
+import (
+	"net/http"
+
+	"restapi/exceptions"
+	"restapi/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Reload re-reads the configuration, like SIGHUP does, and answers with
+// the settings that changed. Settings that could not be applied are listed
+// in Errors.
+func (ac *Controller) Reload(c *gin.Context) {
+	changes, err := ac.reloader.Reload(c.Request.Context())
+	if err != nil && changes == nil {
+		_ = c.Error(exceptions.NewValidation("configuration not reloaded: " + err.Error()).WithCode("invalid_configuration"))
+
+		return
+	}
+
+	var errs []helpers.Error
+	if err != nil {
+		errs = append(errs, helpers.InternalServerError(err.Error()))
+	}
+
+	c.JSON(http.StatusOK, helpers.NewResponse(changes, errs))
+}
@@ -0,0 +1,23 @@
+package rules
+
+import (
+	rules "restapi/internal/service/rules"
+
+	"restapi/db"
+)
+
+type Controller struct {
+	rulesService *rules.Service
+}
+
+func NewRulesController(replicas *db.ReplicaSet,
+	masterDB *db.DB) *Controller {
+
+	if replicas == nil || masterDB == nil {
+		panic("db cannot be null")
+	}
+
+	return &Controller{
+		rulesService: rules.NewRulesService(replicas, masterDB),
+	}
+}
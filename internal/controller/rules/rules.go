@@ -0,0 +1,163 @@
+package rules
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"restapi/exceptions"
+	"restapi/helpers"
+	"restapi/internal/middlewares"
+	models "restapi/internal/model"
+	rules "restapi/internal/service/rules"
+	"restapi/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ruleRequest struct {
+	Type       string          `json:"Type" binding:"required,max=32,code"`
+	Expression string          `json:"Expression" binding:"required,max=1024"`
+	Scale      int             `json:"Scale" binding:"gte=0,lte=6"`
+	Info       json.RawMessage `json:"Info"`
+}
+
+type dryRunRequest struct {
+	// Expression is tried instead of the saved rules when given
+	Expression string                 `json:"Expression" binding:"max=1024"`
+	Scale      int                    `json:"Scale" binding:"gte=0,lte=6"`
+	Variables  map[string]interface{} `json:"Variables"`
+}
+
+type ruleView struct {
+	ID         int             `json:"Id"`
+	ActionID   int             `json:"ActionId"`
+	Type       string          `json:"Type"`
+	Expression string          `json:"Expression"`
+	Scale      int             `json:"Scale"`
+	Info       json.RawMessage `json:"Info,omitempty"`
+	ModifiedBy string          `json:"ModifiedBy,omitempty"`
+}
+
+func presentRule(rule models.ActionCurrencyRule) ruleView {
+	view := ruleView{
+		ID:         rule.ID,
+		ActionID:   rule.ActionID,
+		Type:       rule.Type,
+		Expression: rule.Expression,
+		Scale:      rule.Scale,
+		ModifiedBy: rule.ModifiedBy,
+	}
+
+	if json.Valid([]byte(rule.Info)) {
+		view.Info = json.RawMessage(rule.Info)
+	}
+
+	return view
+}
+
+func (rc *Controller) List(c *gin.Context) {
+	actionID, err := actionIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	result, err := rc.rulesService.List(c.Request.Context(), actionID)
+	if err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	views := make([]ruleView, 0, len(result))
+	for _, rule := range result {
+		views = append(views, presentRule(rule))
+	}
+
+	c.JSON(http.StatusOK, helpers.NewResponse(views, nil))
+}
+
+func (rc *Controller) Add(c *gin.Context) {
+	actionID, err := actionIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	var request ruleRequest
+	if err := validation.BindJSON(c, &request); err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	rule := models.ActionCurrencyRule{
+		ActionID:   actionID,
+		Type:       request.Type,
+		Expression: request.Expression,
+		Scale:      request.Scale,
+		Info:       string(request.Info),
+	}
+
+	if identity := middlewares.GetIdentity(c); identity != nil {
+		rule.ModifiedBy = identity.Name
+	}
+
+	result, err := rc.rulesService.Add(c.Request.Context(), rule)
+	if err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	c.JSON(http.StatusCreated, helpers.NewResponse(presentRule(*result), nil))
+}
+
+// DryRun shows how the rules of the action, or the expression in the
+// body, evaluate for the sample Variables without saving anything.
+func (rc *Controller) DryRun(c *gin.Context) {
+	actionID, err := actionIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	var request dryRunRequest
+	if err := validation.BindJSON(c, &request); err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	result, err := rc.rulesService.DryRun(c.Request.Context(), actionID, rules.DryRun{
+		Expression: request.Expression,
+		Scale:      request.Scale,
+		Variables:  request.Variables,
+	})
+	if err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, helpers.NewResponse(result, nil))
+}
+
+// Variables lists the variables and their types expressions may use.
+func (rc *Controller) Variables(c *gin.Context) {
+	c.JSON(http.StatusOK, helpers.NewResponse(rc.rulesService.Variables(), nil))
+}
+
+func actionIDParam(c *gin.Context) (int, error) {
+	actionID, err := strconv.ParseInt(c.Param("id"), 10, 32)
+	if err != nil || actionID <= 0 {
+		return 0, exceptions.NewValidation("invalid action id",
+			exceptions.FieldError{Field: "id", Rule: "gt", Message: "must be a positive integer"})
+	}
+
+	return int(actionID), nil
+}
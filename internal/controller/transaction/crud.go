@@ -0,0 +1,138 @@
+package transaction
+
+import (
+	"net/http"
+	"strconv"
+
+	"restapi/exceptions"
+	"restapi/helpers"
+	transaction "restapi/internal/service/transaction"
+	"restapi/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (ac *Controller) Create(c *gin.Context) {
+	var request transactionCreateRequest
+	if err := validation.BindJSON(c, &request); err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	result, err := ac.actionService.Create(c.Request.Context(), request.model())
+	if err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	c.JSON(http.StatusCreated, helpers.NewResponse(presentTransaction(*result), nil))
+}
+
+func (ac *Controller) Get(c *gin.Context) {
+	txnID, err := txnIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	fields, err := parseFields(c.Query("fields"))
+	if err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	// ?consistency=strong reads the row from the master
+	result, err := ac.actionService.Get(c.Request.Context(), txnID, c.Query("consistency") == "strong")
+	if err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, helpers.NewResponse(fields.Select(presentTransaction(*result)), nil))
+}
+
+func (ac *Controller) Update(c *gin.Context) {
+	txnID, err := txnIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	var request transactionRequest
+	if err := validation.BindJSON(c, &request); err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	result, err := ac.actionService.Update(c.Request.Context(), txnID, request.model())
+	if err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, helpers.NewResponse(presentTransaction(*result), nil))
+}
+
+func (ac *Controller) Patch(c *gin.Context) {
+	txnID, err := txnIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	var request transactionPatchRequest
+	if err := validation.BindJSON(c, &request); err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	result, err := ac.actionService.Patch(c.Request.Context(), txnID, transaction.Patch{
+		Code:         request.Code,
+		CompanyId:    request.CompanyId,
+		JobprofileId: request.JobprofileId,
+	})
+	if err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, helpers.NewResponse(presentTransaction(*result), nil))
+}
+
+func (ac *Controller) Delete(c *gin.Context) {
+	txnID, err := txnIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	if err := ac.actionService.Delete(c.Request.Context(), txnID); err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, helpers.NewResponse(nil, nil))
+}
+
+func txnIDParam(c *gin.Context) (int64, error) {
+	txnID, err := strconv.ParseInt(c.Param("id"), 10, 32)
+	if err != nil || txnID <= 0 {
+		return 0, exceptions.NewValidation("invalid transaction id",
+			exceptions.FieldError{Field: "id", Rule: "gt", Message: "must be a positive integer"})
+	}
+
+	return txnID, nil
+}
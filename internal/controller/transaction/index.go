@@ -10,14 +10,16 @@ type Controller struct {
 	actionService *transaction.Service
 }
 
-func NewTransactionController(dB *db.DB,
-	masterDB *db.DB) *Controller {
+func NewTransactionController(replicas *db.ReplicaSet,
+	masterDB *db.DB, opts transaction.Options) *Controller {
 
-	if dB == nil {
+	if replicas == nil || masterDB == nil {
 		panic("db cannot be null")
 	}
 
+	registerRequestRules()
+
 	return &Controller{
-		actionService: transaction.NewTransactionService(dB, masterDB),
+		actionService: transaction.NewTransactionService(replicas, masterDB, opts),
 	}
 }
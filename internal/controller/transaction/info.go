@@ -1,35 +1,125 @@
 package transaction
 
 import (
+	"math"
 	"net/http"
+	"strconv"
+
+	"restapi/exceptions"
 	"restapi/helpers"
+	"restapi/internal/dao/mysql"
 	models "restapi/internal/model"
 
 	"github.com/gin-gonic/gin"
 )
 
-type actionInfo struct {
-	Code               string      `json:"Code"`
-	InfoJSON           interface{} `json:"InfoJson"`
-	AdditionalInfoJSON interface{} `json:"AdditionalInfoJson"`
-	CurrencyExpression string      `json:"CurrencyExpression"`
-	Type               string      `json:"Type"`
+func (ac *Controller) Info(c *gin.Context) {
+	filter, err := parseTransactionFilter(c)
+	if err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	fields, err := parseFields(c.Query("fields"))
+	if err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	result, pagination, err := ac.actionService.Info(c.Request.Context(), filter)
+	if err != nil {
+		_ = c.Error(err)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, helpers.NewPaginatedResponse(presentTransactions(result, fields), pagination))
 }
 
-func presentActionsInfo(input []models.Transaction) []actionInfo {
+// parseTransactionFilter reads
+// ?companyId=&jobProfileId=&codePrefix=&sort=&order=asc|desc&limit=&cursor=,
+// ?fields= picks the fields of every row, see parseFields.
+func parseTransactionFilter(c *gin.Context) (models.TransactionFilter, error) {
+	var fields []exceptions.FieldError
+
+	filter := models.TransactionFilter{
+		CompanyId:    int32(positiveIntQuery(c, "companyId", &fields)),
+		JobprofileId: int32(positiveIntQuery(c, "jobProfileId", &fields)),
+		CodePrefix:   c.Query("codePrefix"),
+		SortBy:       c.DefaultQuery("sort", "txnId"),
+		Limit:        positiveIntQuery(c, "limit", &fields),
+	}
 
-	actions := make([]actionInfo, 0)
+	if _, ok := mysql.TransactionSortColumns[filter.SortBy]; !ok {
+		fields = append(fields, exceptions.FieldError{Field: "sort", Rule: "oneof", Message: "unsupported sort column " + filter.SortBy})
+	}
 
-	return actions
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+	case "desc":
+		filter.Descending = true
+	default:
+		fields = append(fields, exceptions.FieldError{Field: "order", Rule: "oneof", Message: "must be asc or desc"})
+	}
+
+	if token := c.Query("cursor"); token != "" {
+		var cursor models.TransactionCursor
+
+		switch err := helpers.DecodeCursor(token, &cursor); {
+		case err != nil:
+			fields = append(fields, exceptions.FieldError{Field: "cursor", Rule: "cursor", Message: "invalid cursor"})
+		// a cursor is only meaningful for the ordering it was issued for
+		case cursor.SortBy != filter.SortBy || cursor.Descending != filter.Descending:
+			fields = append(fields, exceptions.FieldError{Field: "cursor", Rule: "cursor", Message: "cursor does not match sort and order"})
+		case !checkSortValue(&cursor):
+			fields = append(fields, exceptions.FieldError{Field: "cursor", Rule: "cursor", Message: "invalid cursor"})
+		default:
+			filter.After = &cursor
+		}
+	}
+
+	if len(fields) > 0 {
+		return filter, exceptions.NewValidation("invalid query", fields...)
+	}
+
+	return filter, nil
 }
 
-func (ac *Controller) Info(c *gin.Context) {
-	defer helpers.Recover(c, "all-actions-info")
+// checkSortValue reports whether the sort value of cursor has the type of
+// its column, turning the JSON numbers of id columns back into integers.
+func checkSortValue(cursor *models.TransactionCursor) bool {
+	switch value := cursor.SortValue.(type) {
+	case string:
+		return cursor.SortBy == "code"
+	case float64:
+		if cursor.SortBy == "code" || value != math.Trunc(value) || value < math.MinInt32 || value > math.MaxInt32 {
+			return false
+		}
 
-	result, err := ac.actionService.Info()
-	if err != nil {
-		panic(err)
+		cursor.SortValue = int32(value)
+
+		return true
+	default:
+		return false
+	}
+}
+
+// positiveIntQuery returns 0 when key is absent, and records a field error
+// when it is not a positive integer.
+func positiveIntQuery(c *gin.Context, key string, fields *[]exceptions.FieldError) int {
+	value := c.Query(key)
+	if value == "" {
+		return 0
+	}
+
+	parsed, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || parsed <= 0 {
+		*fields = append(*fields, exceptions.FieldError{Field: key, Rule: "gt", Message: "must be a positive integer"})
+
+		return 0
 	}
 
-	c.JSON(http.StatusOK, helpers.NewResponse(result, nil))
+	return int(parsed)
 }
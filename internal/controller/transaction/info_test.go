@@ -0,0 +1,32 @@
+package transaction
+
+import (
+	"testing"
+
+	models "restapi/internal/model"
+)
+
+func TestCheckSortValue(t *testing.T) {
+	tests := []struct {
+		sortBy string
+		value  interface{}
+		want   bool
+	}{
+		{sortBy: "code", value: "abc", want: true},
+		{sortBy: "txnId", value: float64(42), want: true},
+		{sortBy: "code", value: float64(42), want: false},
+		{sortBy: "txnId", value: "42", want: false},
+		{sortBy: "txnId", value: 4.2, want: false},
+		{sortBy: "companyId", value: map[string]interface{}{"a": 1}, want: false},
+		{sortBy: "companyId", value: []interface{}{1}, want: false},
+		{sortBy: "txnId", value: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		cursor := models.TransactionCursor{SortBy: tt.sortBy, SortValue: tt.value}
+
+		if got := checkSortValue(&cursor); got != tt.want {
+			t.Errorf("%s %v: Got: %v Want: %v", tt.sortBy, tt.value, got, tt.want)
+		}
+	}
+}
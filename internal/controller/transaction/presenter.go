@@ -0,0 +1,52 @@
+package transaction
+
+import (
+	"restapi/helpers"
+	models "restapi/internal/model"
+)
+
+// transactionView is how a transaction is shown to clients, together with
+// the settings of its action.
+type transactionView struct {
+	TxnId              int32                  `json:"TxnId"`
+	Code               string                 `json:"Code"`
+	CompanyId          int32                  `json:"CompanyId"`
+	JobprofileId       int32                  `json:"JobprofileId"`
+	ActionId           *int64                 `json:"ActionId,omitempty"`
+	UserId             string                 `json:"UserId,omitempty"`
+	InfoJSON           *models.Info           `json:"InfoJson,omitempty"`
+	AdditionalInfoJSON *models.AdditionalInfo `json:"AdditionalInfoJson,omitempty"`
+	CurrencyExpression string                 `json:"CurrencyExpression,omitempty"`
+	Type               string                 `json:"Type,omitempty"`
+}
+
+func presentTransaction(input models.Transaction) transactionView {
+	return transactionView{
+		TxnId:              input.TxnId,
+		Code:               input.Code,
+		CompanyId:          input.CompanyId,
+		JobprofileId:       input.JobprofileId,
+		ActionId:           input.ActionId.Ptr(),
+		UserId:             input.UserId.ValueOrZero(),
+		InfoJSON:           input.InfoJSON,
+		AdditionalInfoJSON: input.AdditionalInfoJSON,
+		CurrencyExpression: input.CurrencyExpression.ValueOrZero(),
+		Type:               input.Type.ValueOrZero(),
+	}
+}
+
+// presentTransactions shows input, keeping only fields when any are given.
+func presentTransactions(input []models.Transaction, fields helpers.Fieldset) []interface{} {
+	views := make([]interface{}, 0, len(input))
+
+	for _, transaction := range input {
+		views = append(views, fields.Select(presentTransaction(transaction)))
+	}
+
+	return views
+}
+
+// parseFields reads ?fields= against the fields of transactionView.
+func parseFields(query string) (helpers.Fieldset, error) {
+	return helpers.ParseFields(query, transactionView{})
+}
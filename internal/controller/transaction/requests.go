@@ -0,0 +1,67 @@
+package transaction
+
+import (
+	"sync"
+
+	models "restapi/internal/model"
+	"restapi/validation"
+
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/guregu/null.v4"
+)
+
+type transactionRequest struct {
+	Code         string `json:"Code" binding:"required,max=64,code"`
+	CompanyId    int32  `json:"CompanyId" binding:"required,gt=0"`
+	JobprofileId int32  `json:"JobprofileId" binding:"required,gt=0"`
+}
+
+func (r transactionRequest) model() models.Transaction {
+	return models.Transaction{
+		Code:         r.Code,
+		CompanyId:    r.CompanyId,
+		JobprofileId: r.JobprofileId,
+	}
+}
+
+// transactionCreateRequest is a transactionRequest that may reward UserId
+// for ActionId.
+type transactionCreateRequest struct {
+	Code         string `json:"Code" binding:"required,max=64,code"`
+	CompanyId    int32  `json:"CompanyId" binding:"required,gt=0"`
+	JobprofileId int32  `json:"JobprofileId" binding:"required,gt=0"`
+	ActionId     *int32 `json:"ActionId" binding:"omitempty,gt=0"`
+	UserId       string `json:"UserId" binding:"required_with=ActionId,omitempty,max=64"`
+}
+
+func (r transactionCreateRequest) model() models.Transaction {
+	transaction := models.Transaction{
+		Code:         r.Code,
+		CompanyId:    r.CompanyId,
+		JobprofileId: r.JobprofileId,
+	}
+
+	if r.ActionId != nil {
+		transaction.ActionId = null.IntFrom(int64(*r.ActionId))
+		transaction.UserId = null.StringFrom(r.UserId)
+	}
+
+	return transaction
+}
+
+type transactionPatchRequest struct {
+	Code         *string `json:"Code" binding:"omitempty,min=1,max=64,code"`
+	CompanyId    *int32  `json:"CompanyId" binding:"omitempty,gt=0"`
+	JobprofileId *int32  `json:"JobprofileId" binding:"omitempty,gt=0"`
+}
+
+var registerRules sync.Once
+
+// registerRequestRules adds the rules that span the fields of the requests.
+func registerRequestRules() {
+	registerRules.Do(func() {
+		validation.RegisterStructRule(func(sl validator.StructLevel) {
+			validation.AtLeastOne(sl, "Code", "CompanyId", "JobprofileId")
+		}, transactionPatchRequest{})
+	})
+}
@@ -0,0 +1,150 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"restapi/db"
+	"restapi/exceptions"
+	model "restapi/internal/model"
+)
+
+// IdempotencyDao keeps the responses of requests sent with an
+// Idempotency-Key. It only uses the master, replicas could miss a key that
+// was just reserved.
+type IdempotencyDao struct {
+	*database
+}
+
+func NewIdempotencyDao(replicas *db.ReplicaSet, masterDB *db.DB) *IdempotencyDao {
+	return &IdempotencyDao{
+		database: newDatabase(replicas, masterDB),
+	}
+}
+
+// reserveAttempts bounds how often Reserve inserts again when the record it
+// collided with was released before it could be read.
+const reserveAttempts = 3
+
+// Reserve records record as in flight until ttl passes. When the key is
+// already taken it returns the existing record instead, after dropping it
+// if it expired or stayed in flight for longer than stale, as a crashed
+// request leaves it.
+func (id *IdempotencyDao) Reserve(ctx context.Context, record model.IdempotencyRecord, ttl time.Duration, stale time.Duration) (*model.IdempotencyRecord, error) {
+	handle := id.writer()
+
+	ctx, cancel := handle.WithQueryTimeout(ctx)
+	defer cancel()
+
+	for attempt := 0; attempt < reserveAttempts; attempt++ {
+		_, err := handle.Dbx.ExecContext(ctx, `
+			DELETE FROM idempotency_keys
+			WHERE
+				Scope = ?
+				AND IdempotencyKey = ?
+				AND (Expires < NOW() OR (Status IS NULL AND Created < NOW(3) - INTERVAL ? MICROSECOND))
+		`, record.Scope, record.Key, stale.Microseconds())
+		if err != nil {
+			return nil, exceptions.MapDBError(err)
+		}
+
+		_, err = handle.Dbx.ExecContext(ctx, `
+			INSERT INTO idempotency_keys (Scope, IdempotencyKey, RequestHash, Expires)
+			VALUES (?, ?, ?, NOW() + INTERVAL ? SECOND)
+		`, record.Scope, record.Key, record.RequestHash, int64(ttl.Seconds()))
+		if err == nil {
+			return nil, nil
+		}
+
+		if err = exceptions.MapDBError(err); !exceptions.Is(err, exceptions.Conflict) {
+			return nil, err
+		}
+
+		var existing model.IdempotencyRecord
+
+		err = handle.Dbx.GetContext(ctx, &existing, `
+			SELECT
+				Scope,
+				IdempotencyKey,
+				RequestHash,
+				Status,
+				ContentType,
+				Body
+			FROM idempotency_keys
+			WHERE
+				Scope = ?
+				AND IdempotencyKey = ?
+		`, record.Scope, record.Key)
+		if errors.Is(err, sql.ErrNoRows) {
+			// the first request released the key in between, try again
+			continue
+		}
+
+		return &existing, exceptions.MapDBError(err)
+	}
+
+	// the key keeps changing hands, report it as in flight so that the
+	// caller retries later
+	return &record, nil
+}
+
+// Complete stores the response to the reserved record.
+func (id *IdempotencyDao) Complete(ctx context.Context, record model.IdempotencyRecord) error {
+	handle := id.writer()
+
+	ctx, cancel := handle.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := handle.Dbx.ExecContext(ctx, `
+		UPDATE idempotency_keys
+		SET
+			Status = ?,
+			ContentType = ?,
+			Body = ?
+		WHERE
+			Scope = ?
+			AND IdempotencyKey = ?
+	`, record.Status, record.ContentType, record.Body, record.Scope, record.Key)
+
+	return exceptions.MapDBError(err)
+}
+
+// Release drops an in flight record, so that the request can be retried.
+func (id *IdempotencyDao) Release(ctx context.Context, scope string, key string) error {
+	handle := id.writer()
+
+	ctx, cancel := handle.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := handle.Dbx.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE
+			Scope = ?
+			AND IdempotencyKey = ?
+			AND Status IS NULL
+	`, scope, key)
+
+	return exceptions.MapDBError(err)
+}
+
+// PurgeExpired deletes up to limit expired records and returns how many
+// it deleted.
+func (id *IdempotencyDao) PurgeExpired(ctx context.Context, limit int) (int64, error) {
+	handle := id.writer()
+
+	ctx, cancel := handle.WithQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := handle.Dbx.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE Expires < NOW()
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return 0, exceptions.MapDBError(err)
+	}
+
+	return result.RowsAffected()
+}
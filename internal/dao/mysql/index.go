@@ -1,63 +1,38 @@
 package mysql
 
 import (
-	"context"
-	"os"
-
 	"restapi/db"
-	"restapi/logger"
-	helpers "restapi/util"
-
-	"github.com/jmoiron/sqlx"
 )
 
+// database routes writes and transactions to the master and reads to the
+// replicas, unless forceMaster asks for read-after-write consistency.
 type database struct {
-	db       *db.DB
-	masterDB *db.DB
+	replicas    *db.ReplicaSet
+	masterDB    *db.DB
+	forceMaster bool
 }
 
-// nitin: let's move this to goofy ?
-func (dB *database) Transaction(caller func(tx *sqlx.Tx) (interface{}, error)) (interface{}, error) {
-	transaction, err := dB.db.Dbx.Beginx()
-
-	defer func() {
-		if err := recover(); err != nil {
-			logger.Debug(context.Background(), "panic in transaction", logger.Z{
-				"error": err,
-			})
-
-			if transaction != nil {
-				// nolint:errcheck
-				transaction.Rollback()
-			}
-		}
-	}()
-
-	if err != nil {
-		return nil, err
+func newDatabase(replicas *db.ReplicaSet, masterDB *db.DB) *database {
+	if replicas == nil || masterDB == nil {
+		panic("DB cannot be null")
 	}
 
-	result, err := caller(transaction)
-	if err != nil {
-		errCheck := transaction.Rollback()
-		if errCheck != nil {
-			return nil, errCheck
-		}
+	return &database{replicas: replicas, masterDB: masterDB}
+}
 
-		return nil, err
+func (dB *database) reader() *db.DB {
+	if dB.forceMaster {
+		return dB.masterDB
 	}
 
-	return result, transaction.Commit()
+	return dB.replicas.Next()
 }
 
-func decryptRedeemCode(input string) (string, error) {
-	output, err := helpers.DecryptWithRandomIV([]byte(os.Getenv("ENCRYPTION_SECRET_KEY")), input)
-
-	return string(output), err
+func (dB *database) writer() *db.DB {
+	return dB.masterDB
 }
 
-func encryptRedeemCode(input string) string {
-	return helpers.EncryptWithRandomIV(
-		[]byte(os.Getenv("ENCRYPTION_SECRET_KEY")),
-		[]byte(input))
+// primary returns a copy that reads from the master as well.
+func (dB *database) primary() *database {
+	return &database{replicas: dB.replicas, masterDB: dB.masterDB, forceMaster: true}
 }
@@ -0,0 +1,90 @@
+package mysql
+
+import (
+	"context"
+
+	"restapi/exceptions"
+	model "restapi/internal/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// IsBlacklisted reports whether the user may not earn from any action.
+func (ad *TransactionDao) IsBlacklisted(ctx context.Context, userID string) (bool, error) {
+	var count int
+
+	query := `
+		SELECT COUNT(*)
+		FROM blacklisted_users
+		WHERE UserId = ?
+	`
+
+	handle := ad.reader()
+
+	ctx, cancel := handle.WithQueryTimeout(ctx)
+	defer cancel()
+
+	err := handle.Dbx.GetContext(ctx, &count, query, userID)
+
+	return count > 0, exceptions.MapDBError(err)
+}
+
+// GetActionFrequencyLimit returns an exceptions.NotFound when the action
+// does not exist.
+func (ad *TransactionDao) GetActionFrequencyLimit(ctx context.Context, actionID int) (*model.ActionFrequencyLimit, error) {
+	var limit model.ActionFrequencyLimit
+
+	query := `
+		SELECT
+			Id,
+			FrequencyLimit,
+			FrequencyWindowDays
+		FROM actions
+		WHERE Id = ?
+	`
+
+	handle := ad.reader()
+
+	ctx, cancel := handle.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := handle.Dbx.GetContext(ctx, &limit, query, actionID); err != nil {
+		return nil, exceptions.MapDBError(err)
+	}
+
+	return &limit, nil
+}
+
+// CountUserActionsInInterval counts the transactions of the user for the
+// action in the last days days. Inside a transaction the counted range is
+// locked until commit, so that concurrent inserts for the same user and
+// action wait instead of both passing a limit.
+func (ad *TransactionDao) CountUserActionsInInterval(ctx context.Context, tx *sqlx.Tx, actionID int, userID string, days int) (int, error) {
+	var count int
+
+	query := `
+		SELECT COUNT(*)
+		FROM transactions
+		WHERE actionId = ?
+		AND userId = ?
+		AND created > NOW() - INTERVAL ? DAY
+	`
+
+	var err error
+
+	if tx = inTx(ctx, tx); tx != nil {
+		ctx, cancel := ad.writer().WithQueryTimeout(ctx)
+		defer cancel()
+
+		err = tx.GetContext(ctx, &count, query+" FOR UPDATE", actionID, userID, days)
+	} else {
+		handle := ad.reader()
+
+		ctx, cancel := handle.WithQueryTimeout(ctx)
+		defer cancel()
+
+		err = handle.Dbx.GetContext(ctx, &count, query, actionID, userID, days)
+	}
+
+	return count, exceptions.MapDBError(err)
+}
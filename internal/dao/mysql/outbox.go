@@ -0,0 +1,174 @@
+package mysql
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"restapi/db"
+	"restapi/exceptions"
+	"restapi/helpers"
+	model "restapi/internal/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// maxOutboxErrorLength is the size of the LastError column, in characters.
+const maxOutboxErrorLength = 1024
+
+// OutboxDao reads and writes the outbox table, always on the master.
+type OutboxDao struct {
+	*database
+}
+
+func NewOutboxDao(replicas *db.ReplicaSet, masterDB *db.DB) *OutboxDao {
+	return &OutboxDao{
+		database: newDatabase(replicas, masterDB),
+	}
+}
+
+// Add queues message, in the transaction of tx or ctx so that it is only
+// published when the rows it describes are committed.
+func (od *OutboxDao) Add(ctx context.Context, tx *sqlx.Tx, message *model.OutboxMessage) (int64, error) {
+	query := helpers.CreateInsertQuery("outbox", []string{
+		"Topic",
+		"MessageKey",
+		"Payload",
+		"Headers",
+	})
+
+	handle := od.writer()
+
+	ctx, cancel := handle.WithQueryTimeout(ctx)
+	defer cancel()
+
+	tx = inTx(ctx, tx)
+	if tx == nil {
+		return 0, exceptions.NewInternal("outbox messages must be added in a transaction")
+	}
+
+	res, err := tx.NamedExecContext(ctx, query, message)
+	if err != nil {
+		return 0, exceptions.MapDBError(err)
+	}
+
+	return res.LastInsertId()
+}
+
+// LockPending returns up to limit unsent messages, oldest first, locked
+// until tx ends, leaving out the messages of the keys in skip. Relays
+// running at the same time wait for each other, so that messages of a key
+// are published in order.
+func (od *OutboxDao) LockPending(ctx context.Context, tx *sqlx.Tx, limit int, skip []model.OutboxKey) ([]model.OutboxMessage, error) {
+	messages := make([]model.OutboxMessage, 0)
+
+	args := make([]interface{}, 0, 2*len(skip)+1)
+	skipped := ""
+
+	if len(skip) > 0 {
+		pairs := make([]string, 0, len(skip))
+		for _, key := range skip {
+			pairs = append(pairs, "(?, ?)")
+			args = append(args, key.Topic, []byte(key.Key))
+		}
+
+		skipped = "AND (Topic, MessageKey) NOT IN (" + strings.Join(pairs, ", ") + ")"
+	}
+
+	args = append(args, limit)
+
+	query := `
+		SELECT
+			Id,
+			Topic,
+			MessageKey,
+			Payload,
+			Headers,
+			Attempts,
+			LastError
+		FROM outbox
+		WHERE
+			Sent IS NULL
+			` + skipped + `
+		ORDER BY Id
+		LIMIT ?
+		FOR UPDATE
+	`
+
+	ctx, cancel := od.writer().WithQueryTimeout(ctx)
+	defer cancel()
+
+	err := tx.SelectContext(ctx, &messages, query, args...)
+
+	return messages, exceptions.MapDBError(err)
+}
+
+// MarkSent records that the messages with ids were published.
+func (od *OutboxDao) MarkSent(ctx context.Context, tx *sqlx.Tx, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query, args, err := sqlx.In(`
+		UPDATE outbox
+		SET Sent = NOW(3)
+		WHERE Id IN (?)
+	`, ids)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := od.writer().WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err = tx.ExecContext(ctx, query, args...)
+
+	return exceptions.MapDBError(err)
+}
+
+// MarkFailed counts a failed attempt to publish the message with id.
+func (od *OutboxDao) MarkFailed(ctx context.Context, tx *sqlx.Tx, id int64, cause error) error {
+	// the column is utf8mb4, invalid or split characters fail the update
+	message := []rune(strings.ToValidUTF8(cause.Error(), "\uFFFD"))
+	if len(message) > maxOutboxErrorLength {
+		message = message[:maxOutboxErrorLength]
+	}
+
+	query := `
+		UPDATE outbox
+		SET
+			Attempts = Attempts + 1,
+			LastError = ?
+		WHERE Id = ?
+	`
+
+	ctx, cancel := od.writer().WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, string(message), id)
+
+	return exceptions.MapDBError(err)
+}
+
+// PurgeSent deletes up to limit messages sent more than retention ago and
+// returns how many it deleted.
+func (od *OutboxDao) PurgeSent(ctx context.Context, retention time.Duration, limit int) (int64, error) {
+	query := `
+		DELETE FROM outbox
+		WHERE Sent < NOW(3) - INTERVAL ? SECOND
+		ORDER BY Sent
+		LIMIT ?
+	`
+
+	handle := od.writer()
+
+	ctx, cancel := handle.WithQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := handle.Dbx.ExecContext(ctx, query, int64(retention.Seconds()), limit)
+	if err != nil {
+		return 0, exceptions.MapDBError(err)
+	}
+
+	return result.RowsAffected()
+}
@@ -1,19 +1,25 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"restapi/exceptions"
 	"restapi/helpers"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
 
 	model "restapi/internal/model"
 )
 
-func (ad *TransactionDao) Create(tx *sqlx.Tx, action *model.Transaction) (int64, error) {
+func (ad *TransactionDao) Create(ctx context.Context, tx *sqlx.Tx, action *model.Transaction) (int64, error) {
 	query := helpers.CreateInsertQuery("transactions", []string{
 		"code",
 		"companyId",
 		"jobProfileId",
+		"actionId",
+		"userId",
 	})
 
 	var (
@@ -21,166 +27,244 @@ func (ad *TransactionDao) Create(tx *sqlx.Tx, action *model.Transaction) (int64,
 		err error
 	)
 
-	if tx != nil {
-		res, err = tx.NamedExec(query, action)
+	handle := ad.writer()
+
+	ctx, cancel := handle.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if tx = inTx(ctx, tx); tx != nil {
+		res, err = tx.NamedExecContext(ctx, query, action)
 	} else {
-		res, err = ad.db.Dbx.NamedExec(query, action)
+		res, err = handle.Dbx.NamedExecContext(ctx, query, action)
 	}
 
 	if err != nil {
-		return 0, err
+		return 0, exceptions.MapDBError(err)
 	}
 
 	return res.LastInsertId()
 }
 
-// func (ad *TransactionDao) Update(tx *sqlx.Tx, action *model.Transaction) (int64, error) {
-// 	query := `UPDATE
-// 		actions
-// 		SET
-// 		Info = ?,
-// 		AdditionalInfo = ?
-// 		WHERE Code = ?
-// 		`
-
-// 	var (
-// 		res sql.Result
-// 		err error
-// 	)
-
-// 	if tx != nil {
-// 		res, err = tx.Exec(query,
-// 			action.Info, action.AdditionalInfo, action.Code)
-// 	} else {
-// 		res, err = ad.db.Dbx.Exec(query,
-// 			action.Info, action.AdditionalInfo, action.Code)
-// 	}
-
-// 	if err != nil {
-// 		return 0, err
-// 	}
-
-// 	return res.RowsAffected()
-// }
-
-// func (ad *TransactionDao) Delete(code string) error {
-// 	query := `DELETE FROM actions WHERE Code = ?`
-
-// 	_, err := ad.db.Dbx.Exec(query, code)
-
-// 	return err
-// }
-
-// func (ad *TransactionDao) GetActionByCode(code string) (*model.Transaction, error) {
-// 	var action model.Transaction
-
-// 	query := `SELECT
-// 			Id,
-// 			Code,
-// 			Info,
-// 			Active,
-// 			CurrencyExpression,
-// 			AdditionalInfo,
-// 			Type
-// 		FROM
-// 			actions
-// 		WHERE
-// 			Active = 1 AND
-// 			Code = ?
-// 	`
-
-// 	err := ad.db.Dbx.Get(&action, query, code)
-// 	if err != nil {
-// 		return &action, err
-// 	}
-
-// 	action.UnmarshalInfo()
-// 	action.UnmarshalAdditionalInfo()
-
-// 	return &action, nil
-// }
-
-// func (ad *TransactionDao) IsValidUser(userID string) bool {
-// 	var blockedUser null.Int
-
-// 	query := `
-// 		SELECT
-// 			UserId
-// 		FROM blacklisted_users
-// 		WHERE UserId = ?
-// 	`
-
-// 	err := ad.db.Dbx.Get(&blockedUser, query, userID)
-
-// 	return err != nil
-// }
-
-// func (ad *TransactionDao) FetchActionCurrencyRules(actionID int) ([]model.TransactionCurrency, error) {
-// 	var actionCurrency []model.TransactionCurrency
-
-// 	query := `
-// 		Select
-// 			Id,
-// 			ActionId,
-// 			Type,
-// 			Info
-// 		FROM action_currency_rules
-// 		WHERE
-// 			ActionId = ?
-// 	`
-
-// 	err := ad.db.Dbx.Select(&actionCurrency, query, actionID)
-
-// 	return actionCurrency, err
-// }
-
-// func (ad *TransactionDao) CountUserActionsInInterval(actionID int, userID string, days int) (int, error) {
-// 	var count null.Int
-
-// 	query := `
-// 		SELECT COUNT(*)
-// 		FROM transactions
-// 		JOIN
-// 			actions ON
-// 			actions.Id = transactions.ActionId
-// 		WHERE actions.Id = ? AND
-// 		transactions.UserId = ?
-// 		AND transactions.Created > NOW() - INTERVAL ? DAY
-// 	`
-
-// 	err := ad.db.Dbx.Get(&count, query, actionID, userID, days)
-
-// 	return int(count.ValueOrZero()), err
-// }
-
-// func (ad *TransactionDao) AddActionCurrencyRules(currencyRules model.TransactionCurrency) (int64, error) {
-// 	query := helpers.CreateInsertQuery("action_currency_rules", []string{
-// 		"ActionId",
-// 		"Type",
-// 		"Info",
-// 		"ModifiedBy",
-// 	})
-
-// 	res, err := ad.db.Dbx.NamedExec(query, currencyRules)
-// 	if err != nil {
-// 		return 0, err
-// 	}
-
-// 	return res.LastInsertId()
-// }
-
-func (ad *TransactionDao) FetchAllActiveActions() ([]model.Transaction, error) {
-	var actions []model.Transaction
+func (ad *TransactionDao) Update(ctx context.Context, tx *sqlx.Tx, action *model.Transaction) (int64, error) {
+	query := `UPDATE
+		transactions
+		SET
+		code = :code,
+		companyId = :companyId,
+		jobProfileId = :jobProfileId
+		WHERE txnId = :txnId
+		`
+
+	var (
+		res sql.Result
+		err error
+	)
+
+	handle := ad.writer()
+
+	ctx, cancel := handle.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if tx = inTx(ctx, tx); tx != nil {
+		res, err = tx.NamedExecContext(ctx, query, action)
+	} else {
+		res, err = handle.Dbx.NamedExecContext(ctx, query, action)
+	}
+
+	if err != nil {
+		return 0, exceptions.MapDBError(err)
+	}
+
+	return res.RowsAffected()
+}
+
+func (ad *TransactionDao) Delete(ctx context.Context, tx *sqlx.Tx, txnID int64) (int64, error) {
+	query := `DELETE FROM transactions WHERE txnId = ?`
+
+	var (
+		res sql.Result
+		err error
+	)
+
+	handle := ad.writer()
+
+	ctx, cancel := handle.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if tx = inTx(ctx, tx); tx != nil {
+		res, err = tx.ExecContext(ctx, query, txnID)
+	} else {
+		res, err = handle.Dbx.ExecContext(ctx, query, txnID)
+	}
+
+	if err != nil {
+		return 0, exceptions.MapDBError(err)
+	}
+
+	return res.RowsAffected()
+}
+
+// GetTransactionByID returns an exceptions.NotFound wrapping
+// sql.ErrNoRows when the row does not exist.
+// Inside a transaction the row is locked until commit so that
+// read-modify-write callers do not race each other.
+func (ad *TransactionDao) GetTransactionByID(ctx context.Context, tx *sqlx.Tx, txnID int64) (*model.Transaction, error) {
+	var action model.Transaction
+
+	var err error
+
+	if tx = inTx(ctx, tx); tx != nil {
+		ctx, cancel := ad.writer().WithQueryTimeout(ctx)
+		defer cancel()
+
+		// the action is read apart: it is shared by many transactions, and
+		// locking it would serialise their updates
+		query := `SELECT ` + transactionRowColumns + `
+			FROM transactions t
+			WHERE t.txnId = ?
+			FOR UPDATE
+		`
+
+		err = tx.GetContext(ctx, &action, query, txnID)
+		if err == nil && action.ActionId.Valid {
+			query := `SELECT ` + actionColumns + `
+				FROM actions a
+				WHERE a.Id = ?
+			`
+
+			// like the LEFT JOIN, a missing action leaves its settings null
+			if err = tx.GetContext(ctx, &action, query, action.ActionId.Int64); errors.Is(err, sql.ErrNoRows) {
+				err = nil
+			}
+		}
+	} else {
+		handle := ad.reader()
+
+		ctx, cancel := handle.WithQueryTimeout(ctx)
+		defer cancel()
+
+		query := `SELECT ` + transactionColumns + `
+			FROM
+				transactions t
+				LEFT JOIN actions a ON a.Id = t.actionId
+			WHERE
+				t.txnId = ?
+		`
+
+		err = handle.Dbx.GetContext(ctx, &action, query, txnID)
+	}
+
+	if err != nil {
+		return nil, exceptions.MapDBError(err)
+	}
+
+	action.UnmarshalInfo()
+	action.UnmarshalAdditionalInfo()
+
+	return &action, nil
+}
+
+// transactionRowColumns read a transaction, aliased t, and actionColumns
+// the settings of its action, aliased a.
+const (
+	transactionRowColumns = `
+			t.txnId,
+			t.code,
+			t.companyId,
+			t.jobProfileId,
+			t.actionId,
+			t.userId`
+	actionColumns = `
+			a.Info AS info,
+			a.AdditionalInfo AS additionalInfo,
+			a.CurrencyExpression AS currencyExpression,
+			a.Type AS type`
+	transactionColumns = transactionRowColumns + "," + actionColumns
+)
+
+// TransactionSortColumns whitelists the columns a listing can be ordered by.
+var TransactionSortColumns = map[string]string{
+	"txnId":        "t.txnId",
+	"code":         "t.code",
+	"companyId":    "t.companyId",
+	"jobProfileId": "t.jobProfileId",
+}
+
+// FetchAllActiveActions returns up to filter.Limit+1 rows, the extra row
+// lets the caller know whether another page exists.
+func (ad *TransactionDao) FetchAllActiveActions(ctx context.Context, filter model.TransactionFilter) ([]model.Transaction, error) {
+	actions := make([]model.Transaction, 0)
+
+	column, ok := TransactionSortColumns[filter.SortBy]
+	if !ok {
+		column = "t.txnId"
+	}
+
+	direction, comparator := "ASC", ">"
+	if filter.Descending {
+		direction, comparator = "DESC", "<"
+	}
+
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+
+	if filter.CompanyId != 0 {
+		conditions = append(conditions, "t.companyId = ?")
+		args = append(args, filter.CompanyId)
+	}
+
+	if filter.JobprofileId != 0 {
+		conditions = append(conditions, "t.jobProfileId = ?")
+		args = append(args, filter.JobprofileId)
+	}
+
+	if filter.CodePrefix != "" {
+		conditions = append(conditions, "t.code LIKE ?")
+		args = append(args, likeEscaper.Replace(filter.CodePrefix)+"%")
+	}
+
+	if filter.After != nil {
+		if column == "t.txnId" {
+			conditions = append(conditions, "t.txnId "+comparator+" ?")
+			args = append(args, filter.After.TxnId)
+		} else {
+			conditions = append(conditions,
+				"("+column+" "+comparator+" ? OR ("+column+" = ? AND t.txnId "+comparator+" ?))")
+			args = append(args, filter.After.SortValue, filter.After.SortValue, filter.After.TxnId)
+		}
+	}
 
 	query := `
-		SELECT
-			code,
-			companyId,
-			jobProfileId
-		FROM transactions
+		SELECT ` + transactionColumns + `
+		FROM transactions t
+		LEFT JOIN actions a ON a.Id = t.actionId
 	`
 
-	err := ad.db.Dbx.Select(&actions, query)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query += " ORDER BY " + column + " " + direction
+	if column != "t.txnId" {
+		query += ", t.txnId " + direction
+	}
+
+	query += " LIMIT ?"
+	args = append(args, filter.Limit+1)
+
+	handle := ad.reader()
+
+	ctx, cancel := handle.WithQueryTimeout(ctx)
+	defer cancel()
 
-	return actions, err
+	err := handle.Dbx.SelectContext(ctx, &actions, query, args...)
+
+	for i := range actions {
+		actions[i].UnmarshalInfo()
+		actions[i].UnmarshalAdditionalInfo()
+	}
+
+	return actions, exceptions.MapDBError(err)
 }
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
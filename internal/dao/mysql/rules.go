@@ -0,0 +1,86 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	"restapi/db"
+	"restapi/exceptions"
+	"restapi/helpers"
+	model "restapi/internal/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type RuleDao struct {
+	*database
+}
+
+func NewRuleDao(replicas *db.ReplicaSet, masterDB *db.DB) *RuleDao {
+	return &RuleDao{
+		database: newDatabase(replicas, masterDB),
+	}
+}
+
+// FetchActionCurrencyRules returns the rules of the action, oldest first.
+func (rd *RuleDao) FetchActionCurrencyRules(ctx context.Context, actionID int) ([]model.ActionCurrencyRule, error) {
+	rules := make([]model.ActionCurrencyRule, 0)
+
+	query := `
+		SELECT
+			Id,
+			ActionId,
+			Type,
+			Expression,
+			Scale,
+			Info,
+			ModifiedBy
+		FROM action_currency_rules
+		WHERE
+			ActionId = ?
+		ORDER BY Id
+	`
+
+	handle := rd.reader()
+
+	ctx, cancel := handle.WithQueryTimeout(ctx)
+	defer cancel()
+
+	err := handle.Dbx.SelectContext(ctx, &rules, query, actionID)
+
+	return rules, exceptions.MapDBError(err)
+}
+
+// AddActionCurrencyRule inserts rule, the action must exist.
+func (rd *RuleDao) AddActionCurrencyRule(ctx context.Context, tx *sqlx.Tx, rule *model.ActionCurrencyRule) (int64, error) {
+	query := helpers.CreateInsertQuery("action_currency_rules", []string{
+		"ActionId",
+		"Type",
+		"Expression",
+		"Scale",
+		"Info",
+		"ModifiedBy",
+	})
+
+	var (
+		res sql.Result
+		err error
+	)
+
+	handle := rd.writer()
+
+	ctx, cancel := handle.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if tx = inTx(ctx, tx); tx != nil {
+		res, err = tx.NamedExecContext(ctx, query, rule)
+	} else {
+		res, err = handle.Dbx.NamedExecContext(ctx, query, rule)
+	}
+
+	if err != nil {
+		return 0, exceptions.MapDBError(err)
+	}
+
+	return res.LastInsertId()
+}
@@ -8,12 +8,14 @@ type TransactionDao struct {
 	*database
 }
 
-func NewTransactionDao(dB *db.DB) *TransactionDao {
-	if dB == nil {
-		panic("DB cannot be null")
-	}
-
+func NewTransactionDao(replicas *db.ReplicaSet, masterDB *db.DB) *TransactionDao {
 	return &TransactionDao{
-		database: &database{db: dB},
+		database: newDatabase(replicas, masterDB),
 	}
 }
+
+// FromMaster returns a dao whose reads also go to the master, for callers
+// that must see their own writes before replication catches up.
+func (ad *TransactionDao) FromMaster() *TransactionDao {
+	return &TransactionDao{database: ad.primary()}
+}
@@ -0,0 +1,195 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"time"
+
+	"restapi/exceptions"
+	"restapi/logger"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	errLockWaitTimeout = 1205
+	errDeadlock        = 1213
+
+	// maxTxAttempts bounds how often a transaction is run when MySQL keeps
+	// picking it as the deadlock victim
+	maxTxAttempts = 4
+	txBackoff     = 25 * time.Millisecond
+	maxTxBackoff  = 500 * time.Millisecond
+)
+
+// txState is the transaction a context carries, with the depth of the
+// savepoints opened inside it.
+type txState struct {
+	tx    *sqlx.Tx
+	depth int
+}
+
+type txKey struct{}
+
+// TxFromContext returns the transaction ctx runs in, or nil.
+func TxFromContext(ctx context.Context) *sqlx.Tx {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state.tx
+	}
+
+	return nil
+}
+
+// inTx is tx, or the transaction ctx runs in when the caller gave none, so
+// that queries of nested service calls join the outer transaction.
+func inTx(ctx context.Context, tx *sqlx.Tx) *sqlx.Tx {
+	if tx != nil {
+		return tx
+	}
+
+	return TxFromContext(ctx)
+}
+
+// Transaction runs fn in a transaction on the master, committed when fn
+// returns nil and rolled back when it returns an error, panics or ctx is
+// done. opts sets the isolation level and read-only mode, nil keeps the
+// server defaults.
+//
+// fn gets a context carrying the transaction. A Transaction started with
+// it runs in a savepoint of the outer one instead, so that service methods
+// compose: its failure rolls back only its own work and opts is ignored.
+//
+// A transaction that hits a deadlock or lock wait timeout is run again
+// with backoff, so fn must not have side effects outside the database.
+func (dB *database) Transaction(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context, tx *sqlx.Tx) error) error {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return exceptions.MapDBError(savepoint(ctx, state, fn))
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := dB.transaction(ctx, opts, fn)
+		if err == nil || !isRetryable(err) || attempt == maxTxAttempts {
+			return exceptions.MapDBError(err)
+		}
+
+		logger.Debug(ctx, "retrying transaction", logger.Z{
+			"attempt": attempt,
+			"error":   err.Error(),
+		})
+
+		select {
+		case <-time.After(backoff(attempt)):
+		case <-ctx.Done():
+			return exceptions.MapDBError(errors.Join(ctx.Err(), err))
+		}
+	}
+}
+
+// TransactionOnce is Transaction without the retries, for fn with side
+// effects outside the database, such as publishing messages. A deadlock or
+// lock wait timeout is returned to the caller.
+func (dB *database) TransactionOnce(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context, tx *sqlx.Tx) error) error {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return exceptions.MapDBError(savepoint(ctx, state, fn))
+	}
+
+	return exceptions.MapDBError(dB.transaction(ctx, opts, fn))
+}
+
+func (dB *database) transaction(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context, tx *sqlx.Tx) error) (err error) {
+	tx, err := dB.writer().Dbx.BeginTxx(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = panicError(ctx, r)
+		}
+
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil && !errors.Is(rollbackErr, sql.ErrTxDone) {
+				err = errors.Join(err, rollbackErr)
+			}
+		}
+	}()
+
+	if err = fn(context.WithValue(ctx, txKey{}, &txState{tx: tx}), tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func savepoint(ctx context.Context, state *txState, fn func(ctx context.Context, tx *sqlx.Tx) error) (err error) {
+	state.depth++
+	defer func() { state.depth-- }()
+
+	name := fmt.Sprintf("sp_%d", state.depth)
+
+	if _, err = state.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = panicError(ctx, r)
+		}
+
+		if err != nil {
+			if _, rollbackErr := state.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rollbackErr != nil {
+				err = errors.Join(err, rollbackErr)
+			}
+		}
+	}()
+
+	if err = fn(ctx, state.tx); err != nil {
+		return err
+	}
+
+	_, err = state.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+
+	return err
+}
+
+// panicError turns a panic inside a transaction into the error returned to
+// the caller, logging where it happened.
+func panicError(ctx context.Context, r interface{}) error {
+	logger.Error(ctx, "panic in transaction", logger.Z{
+		"error": fmt.Sprintf("%v", r),
+		"stack": string(debug.Stack()),
+	})
+
+	if err, ok := r.(error); ok {
+		return fmt.Errorf("panic in transaction: %w", err)
+	}
+
+	return fmt.Errorf("panic in transaction: %v", r)
+}
+
+// isRetryable reports whether MySQL rolled the transaction back, or gave
+// up waiting for a lock, in a way a new attempt may not run into.
+func isRetryable(err error) bool {
+	var mysqlErr *mysqldriver.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+
+	return mysqlErr.Number == errDeadlock || mysqlErr.Number == errLockWaitTimeout
+}
+
+// backoff doubles with every attempt, with jitter so that the
+// transactions that deadlocked each other do not collide again.
+func backoff(attempt int) time.Duration {
+	wait := txBackoff << (attempt - 1)
+	if wait > maxTxBackoff {
+		wait = maxTxBackoff
+	}
+
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
@@ -0,0 +1,38 @@
+package mysql
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+func TestIsRetryable(t *testing.T) {
+	cases := map[error]bool{
+		&mysqldriver.MySQLError{Number: errDeadlock}:                                  true,
+		fmt.Errorf("update: %w", &mysqldriver.MySQLError{Number: errLockWaitTimeout}): true,
+		&mysqldriver.MySQLError{Number: 1062}:                                         false,
+		errors.New("deadlock"):                                                        false,
+	}
+
+	for err, want := range cases {
+		if got := isRetryable(err); got != want {
+			t.Errorf("isRetryable(%v) = %v, want %v", err, got, want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 1; attempt < 10; attempt++ {
+		wait := backoff(attempt)
+
+		if wait < txBackoff/2 || wait > maxTxBackoff {
+			t.Errorf("attempt %d: %v outside [%v, %v]", attempt, wait, txBackoff/2, maxTxBackoff)
+		}
+	}
+
+	if backoff(3) < 2*txBackoff {
+		t.Errorf("backoff does not grow: %v", backoff(3))
+	}
+}
@@ -0,0 +1,182 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"restapi/helpers"
+	"restapi/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+
+	defaultTimeout = 2 * time.Second
+)
+
+// Check probes one dependency. A failing critical check makes the instance
+// unready, a failing non critical one is only reported.
+type Check struct {
+	Name     string
+	Critical bool
+	Timeout  time.Duration
+	Probe    func(ctx context.Context) error
+}
+
+type Result struct {
+	Name     string `json:"Name"`
+	Status   string `json:"Status"`
+	Critical bool   `json:"Critical"`
+	// Error is only logged, it can name hosts and the probes are public
+	Error      string `json:"-"`
+	DurationMs int64  `json:"DurationMs"`
+}
+
+type Report struct {
+	Status string   `json:"Status"`
+	Checks []Result `json:"Checks,omitempty"`
+}
+
+// Checker serves the liveness and readiness probes. The instance starts out
+// ready, SetReady(false) takes it out of rotation, for example while it
+// drains during shutdown.
+type Checker struct {
+	mu     sync.RWMutex
+	checks []Check
+	ready  atomic.Bool
+}
+
+func NewChecker() *Checker {
+	checker := &Checker{}
+	checker.ready.Store(true)
+
+	return checker
+}
+
+func (hc *Checker) Register(check Check) {
+	if check.Timeout <= 0 {
+		check.Timeout = defaultTimeout
+	}
+
+	hc.mu.Lock()
+	hc.checks = append(hc.checks, check)
+	hc.mu.Unlock()
+}
+
+func (hc *Checker) SetReady(ready bool) {
+	hc.ready.Store(ready)
+}
+
+// Run probes every dependency concurrently, each bounded by its own timeout.
+func (hc *Checker) Run(ctx context.Context) (Report, bool) {
+	hc.mu.RLock()
+	checks := append([]Check(nil), hc.checks...)
+	hc.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+
+	var wg sync.WaitGroup
+
+	for i, check := range checks {
+		wg.Add(1)
+
+		go func(i int, check Check) {
+			defer wg.Done()
+
+			results[i] = run(ctx, check)
+		}(i, check)
+	}
+
+	wg.Wait()
+
+	healthy := hc.ready.Load()
+	report := Report{Status: StatusUp, Checks: results}
+
+	for _, result := range results {
+		if result.Critical && result.Status == StatusDown {
+			healthy = false
+		}
+	}
+
+	if !healthy {
+		report.Status = StatusDown
+	}
+
+	return report, healthy
+}
+
+func run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	result := Result{Name: check.Name, Status: StatusUp, Critical: check.Critical}
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errCh <- helpers.InternalServerError("health probe panicked")
+			}
+		}()
+
+		errCh <- check.Probe(ctx)
+	}()
+
+	var err error
+
+	// a probe that ignores its context must not hold the report hostage
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	return result
+}
+
+// Liveness only tells whether the process is able to serve HTTP at all.
+func (hc *Checker) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, helpers.NewResponse(Report{Status: StatusUp}, nil))
+}
+
+// Readiness reports every dependency and answers 503 when a critical one is
+// down or the instance is draining.
+func (hc *Checker) Readiness(c *gin.Context) {
+	report, healthy := hc.Run(c.Request.Context())
+
+	c.Header("Cache-Control", "no-store, no-cache, must-revalidate, max-age=0")
+
+	failures := logger.Z{}
+	for _, result := range report.Checks {
+		if result.Error != "" {
+			failures[result.Name] = result.Error
+		}
+	}
+
+	if len(failures) > 0 {
+		logger.Error(c, "readiness check failed", logger.Z{"status": report.Status, "errors": failures})
+	}
+
+	if !healthy {
+		c.JSON(http.StatusServiceUnavailable, helpers.NewResponse(report, nil))
+
+		return
+	}
+
+	c.JSON(http.StatusOK, helpers.NewResponse(report, nil))
+}
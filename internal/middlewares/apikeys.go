@@ -0,0 +1,221 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"restapi/config"
+	"restapi/logger"
+)
+
+// APIKey is one named credential accepted by the internal routes.
+type APIKey struct {
+	Name             string   `json:"Name"`
+	Key              string   `json:"Key"`
+	RequireSignature bool     `json:"RequireSignature"`
+	Roles            []string `json:"Roles"`
+}
+
+// APIKeyStore holds the currently valid keys. Keys come from
+// INTERNAL_API_KEYS ("name:key[:signed][:admin],..."), the legacy
+// INTERNAL_API_KEY and the JSON file at INTERNAL_API_KEYS_FILE. The file can
+// be rewritten at any time, Watch picks the new keys up without a restart.
+// Keys without roles may only read and write transactions, the admin option
+// adds the admin role and the file can grant any role.
+type APIKeyStore struct {
+	mu      sync.RWMutex
+	keys    []APIKey
+	cfg     config.APIKeys
+	modTime time.Time
+}
+
+func NewAPIKeyStore(cfg config.APIKeys) (*APIKeyStore, error) {
+	store := &APIKeyStore{}
+
+	return store, store.Configure(cfg)
+}
+
+// Configure replaces the key settings and reloads the keys. On error the
+// previous settings and keys stay in place.
+func (s *APIKeyStore) Configure(cfg config.APIKeys) error {
+	return s.load(cfg)
+}
+
+// Reload re-reads the key file and swaps the key set atomically.
+// On error the previous keys stay in place.
+func (s *APIKeyStore) Reload() error {
+	s.mu.RLock()
+	cfg := s.cfg
+	s.mu.RUnlock()
+
+	return s.load(cfg)
+}
+
+func (s *APIKeyStore) load(cfg config.APIKeys) error {
+	keys, err := parseAPIKeys(cfg.Keys)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Legacy != "" {
+		keys = append(keys, APIKey{Name: "default", Key: cfg.Legacy})
+	}
+
+	var modTime time.Time
+
+	if cfg.File != "" {
+		info, err := os.Stat(cfg.File)
+		if err != nil {
+			return err
+		}
+
+		modTime = info.ModTime()
+
+		fileKeys, err := readAPIKeyFile(cfg.File)
+		if err != nil {
+			return err
+		}
+
+		keys = append(keys, fileKeys...)
+	}
+
+	for i := range keys {
+		if len(keys[i].Roles) == 0 {
+			// admin and the rules have to be granted explicitly
+			keys[i].Roles = defaultKeyRoles()
+		}
+	}
+
+	s.mu.Lock()
+	s.cfg = cfg
+	s.keys = keys
+	s.modTime = modTime
+	s.mu.Unlock()
+
+	if len(keys) == 0 {
+		logger.Error(context.Background(), "no internal api keys configured, internal routes will reject every request", nil)
+	}
+
+	return nil
+}
+
+// Watch polls the key file and reloads it whenever it changes. The file
+// may be set or replaced later through Configure.
+func (s *APIKeyStore) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.mu.RLock()
+			file, modTime := s.cfg.File, s.modTime
+			s.mu.RUnlock()
+
+			if file == "" {
+				continue
+			}
+
+			info, err := os.Stat(file)
+			if err != nil {
+				logger.Error(ctx, "unable to stat api key file", logger.Z{"file": file, "error": err.Error()})
+
+				continue
+			}
+
+			if info.ModTime().Equal(modTime) {
+				continue
+			}
+
+			if err := s.Reload(); err != nil {
+				logger.Error(ctx, "unable to reload api keys", logger.Z{"file": file, "error": err.Error()})
+
+				continue
+			}
+
+			logger.Info(ctx, "reloaded api keys", logger.Z{"file": file})
+		}
+	}
+}
+
+// Lookup finds the key matching secret. Every key is compared so that the
+// time taken does not depend on which one matched.
+func (s *APIKeyStore) Lookup(secret string) (APIKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var (
+		found APIKey
+		ok    bool
+	)
+
+	for _, key := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(key.Key), []byte(secret)) == 1 {
+			found, ok = key, true
+		}
+	}
+
+	return found, ok
+}
+
+func parseAPIKeys(raw string) ([]APIKey, error) {
+	keys := make([]APIKey, 0)
+
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 4 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid INTERNAL_API_KEYS entry %q, expected name:key[:signed][:admin]", parts[0])
+		}
+
+		key := APIKey{Name: parts[0], Key: parts[1]}
+
+		for _, option := range parts[2:] {
+			switch option {
+			case "signed":
+				key.RequireSignature = true
+			case "admin":
+				key.Roles = append(defaultKeyRoles(), RoleAdmin)
+			default:
+				return nil, fmt.Errorf("invalid INTERNAL_API_KEYS option %q for %s", option, parts[0])
+			}
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func readAPIKeyFile(file string) ([]APIKey, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []APIKey
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		return nil, fmt.Errorf("invalid api key file %s: %w", file, err)
+	}
+
+	for _, key := range keys {
+		if key.Name == "" || key.Key == "" {
+			return nil, errors.New("api key file entries need a Name and a Key")
+		}
+	}
+
+	return keys, nil
+}
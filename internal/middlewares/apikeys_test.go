@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"testing"
+
+	"restapi/config"
+)
+
+func TestAPIKeyStore_Roles(t *testing.T) {
+	store, err := NewAPIKeyStore(config.APIKeys{Keys: "billing:b-secret,ops:o-secret:signed:admin", Legacy: "l-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]bool{
+		"b-secret": false,
+		"l-secret": false,
+		"o-secret": true,
+	}
+
+	for secret, admin := range cases {
+		key, ok := store.Lookup(secret)
+		if !ok {
+			t.Fatalf("key %s not found", secret)
+		}
+
+		identity := &Identity{Name: key.Name, Roles: key.Roles}
+
+		if !identity.HasRole(RoleWriteTransactions) {
+			t.Errorf("%s cannot write transactions", key.Name)
+		}
+
+		if identity.HasRole(RoleWriteRules) {
+			t.Errorf("%s can write rules without a grant", key.Name)
+		}
+
+		if identity.HasRole(RoleAdmin) != admin {
+			t.Errorf("%s has admin %t, want %t", key.Name, !admin, admin)
+		}
+	}
+
+	if _, err := parseAPIKeys("ops:o-secret:root"); err == nil {
+		t.Error("an unknown option was accepted")
+	}
+}
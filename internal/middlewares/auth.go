@@ -1,27 +1,161 @@
 package middlewares
 
 import (
+	"context"
+	"errors"
+	"time"
+
+	"restapi/exceptions"
+	"restapi/helpers"
 	"restapi/logger"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AuthInternalRoutes() gin.HandlerFunc {
-	return func(c *gin.Context) {
+// IdentityKey is where the authenticated caller is stored on the gin context.
+const IdentityKey = "IDENTITY"
 
-		// apiKey := c.Request.Header.Get("x-api-key")
+const defaultMaxSkew = 5 * time.Minute
 
-		// if apiKey != os.Getenv("INTERNAL_API_KEY") {
-		// 	logger.Error(c, "access denied", logger.Z{
-		// 		"apiKey": apiKey,
-		// 	})
-		// 	c.AbortWithStatus(http.StatusUnauthorized)
+var errNoCredentials = errors.New("missing credentials")
+
+// Identity describes who made the request and what it may do.
+type Identity struct {
+	Name   string
+	Method string
+	Roles  []string
+}
 
-		// 	return
-		// }
+// Authenticator inspects a request and returns its caller, or a
+// helpers.Error describing why the request is not allowed. When the request
+// does not carry the kind of credential the authenticator understands it
+// returns errNoCredentials so that the next authenticator can be tried.
+type Authenticator interface {
+	Authenticate(c *gin.Context) (*Identity, error)
+}
+
+// APIKeyAuthenticator accepts requests carrying a known x-api-key. When the
+// request is signed, or the key demands it, the HMAC signature is verified
+// as well.
+type APIKeyAuthenticator struct {
+	keys      *APIKeyStore
+	signature *signatureVerifier
+}
 
-		logger.Info(c, "Hi from middleware", nil)
+// NewAPIKeyAuthenticator accepts signed requests whose timestamp is within
+// maxSkew of the server clock, 5 minutes when maxSkew is not positive.
+func NewAPIKeyAuthenticator(keys *APIKeyStore, maxSkew time.Duration) *APIKeyAuthenticator {
+	if maxSkew <= 0 {
+		maxSkew = defaultMaxSkew
+	}
+
+	return &APIKeyAuthenticator{
+		keys:      keys,
+		signature: newSignatureVerifier(maxSkew),
+	}
+}
+
+// SetMaxSkew changes the clock skew allowed for signed requests.
+func (a *APIKeyAuthenticator) SetMaxSkew(maxSkew time.Duration) {
+	if maxSkew <= 0 {
+		maxSkew = defaultMaxSkew
+	}
+
+	a.signature.setMaxSkew(maxSkew)
+}
+
+func (a *APIKeyAuthenticator) Authenticate(c *gin.Context) (*Identity, error) {
+	apiKey := c.Request.Header.Get("x-api-key")
+	if apiKey == "" {
+		return nil, errNoCredentials
+	}
+
+	key, ok := a.keys.Lookup(apiKey)
+	if !ok {
+		return nil, helpers.UnauthorizedError("invalid api key")
+	}
+
+	method := "api-key"
+
+	if key.RequireSignature || c.Request.Header.Get(signatureHeader) != "" {
+		if err := a.signature.Verify(c.Request, key.Key); err != nil {
+			if exceptions.Is(err, exceptions.TooLarge) {
+				return nil, err
+			}
+
+			return nil, helpers.UnauthorizedError(err.Error())
+		}
+
+		method = "hmac"
+	}
+
+	return &Identity{Name: key.Name, Method: method, Roles: key.Roles}, nil
+}
+
+func AuthInternalRoutes(authenticator Authenticator) gin.HandlerFunc {
+	return AuthRoutes(authenticator)
+}
+
+// AuthRoutes tries each authenticator in turn and lets the request through
+// with the first identity found.
+func AuthRoutes(authenticators ...Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, err := authenticate(c, authenticators)
+		if err != nil {
+			logger.Error(c, "access denied", logger.Z{
+				"error": err.Error(),
+				"path":  c.FullPath(),
+			})
+
+			abortWithError(c, err)
+
+			return
+		}
+
+		setIdentity(c, identity)
 
 		c.Next()
 	}
 }
+
+func authenticate(c *gin.Context, authenticators []Authenticator) (*Identity, error) {
+	for _, authenticator := range authenticators {
+		identity, err := authenticator.Authenticate(c)
+		if err == errNoCredentials {
+			continue
+		}
+
+		return identity, err
+	}
+
+	return nil, helpers.UnauthorizedError(errNoCredentials.Error())
+}
+
+// setIdentity exposes the caller to handlers through the gin context and to
+// the logger through both the gin and the request context.
+func setIdentity(c *gin.Context, identity *Identity) {
+	c.Set(IdentityKey, identity)
+	c.Set(logger.CallerKey, identity.Name)
+	c.Request = c.Request.WithContext(
+		context.WithValue(c.Request.Context(), logger.CallerKey, identity.Name))
+}
+
+// GetIdentity returns the caller stored by the auth middlewares.
+func GetIdentity(c *gin.Context) *Identity {
+	identity, _ := c.Value(IdentityKey).(*Identity)
+
+	return identity
+}
+
+func abortWithError(c *gin.Context, err error) {
+	httpErr := exceptions.From(err)
+	if httpErr.Kind == exceptions.Internal {
+		httpErr = exceptions.NewUnauthorized(err.Error())
+	}
+
+	if httpErr.Kind == exceptions.Unauthorized {
+		c.Header("WWW-Authenticate", "Bearer, ApiKey")
+	}
+
+	helpers.AbortWithError(c, httpErr)
+}
@@ -0,0 +1,24 @@
+package middlewares
+
+import (
+	"restapi/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Errors answers requests whose handler recorded an error with c.Error,
+// and did not write a response, with the last of those errors. Handlers
+// that panic are answered the same way.
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer helpers.Recover(c, c.FullPath())
+
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		helpers.AbortWithError(c, c.Errors.Last().Err)
+	}
+}
@@ -0,0 +1,246 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"restapi/config"
+	"restapi/exceptions"
+	"restapi/helpers"
+	models "restapi/internal/model"
+	"restapi/logger"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/guregu/null.v4"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed for a repeated
+	// key
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	// idempotencyStaleMargin is added to the longest request deadline
+	// before an in flight key is taken for one left by a crashed instance
+	idempotencyStaleMargin = 30 * time.Second
+	idempotencyPurgeBatch  = 1000
+)
+
+// IdempotencyStore keeps the outcome of requests by caller and key, see
+// mysql.IdempotencyDao.
+type IdempotencyStore interface {
+	Reserve(ctx context.Context, record models.IdempotencyRecord, ttl time.Duration, stale time.Duration) (*models.IdempotencyRecord, error)
+	Complete(ctx context.Context, record models.IdempotencyRecord) error
+	Release(ctx context.Context, scope string, key string) error
+	PurgeExpired(ctx context.Context, limit int) (int64, error)
+}
+
+// Idempotency makes mutating requests safe to retry. The first request with
+// an Idempotency-Key runs and its response is stored, repeats of the key by
+// the same caller get that response back. A repeat while the first is in
+// flight, or with another method, path or body, is a conflict. Server
+// errors are not stored, so that the request can be retried.
+type Idempotency struct {
+	store IdempotencyStore
+	ttl   time.Duration
+	stale time.Duration
+}
+
+func NewIdempotency(store IdempotencyStore, cfg config.Server) *Idempotency {
+	return &Idempotency{
+		store: store,
+		ttl:   cfg.IdempotencyTTL,
+		stale: cfg.MaxTimeout() + idempotencyStaleMargin,
+	}
+}
+
+// Handle must run after the auth middlewares, keys are scoped to callers.
+// Requests without a key and safe methods pass through.
+func (i *Idempotency) Handle() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || !mutating(c.Request.Method) {
+			c.Next()
+
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			helpers.AbortWithError(c, exceptions.NewValidation("invalid "+IdempotencyKeyHeader, exceptions.FieldError{
+				Field:   IdempotencyKeyHeader,
+				Rule:    "max",
+				Message: "must be at most 255 long",
+			}))
+
+			return
+		}
+
+		body, err := readAndRestoreBody(c.Request)
+		if exceptions.Is(err, exceptions.TooLarge) {
+			helpers.AbortWithError(c, err)
+
+			return
+		}
+
+		if err != nil {
+			helpers.AbortWithError(c, exceptions.NewValidation("unable to read request body").Wrap(err))
+
+			return
+		}
+
+		record := models.IdempotencyRecord{
+			Scope:       callerKey(c),
+			Key:         key,
+			RequestHash: requestHash(c.Request, body),
+		}
+
+		existing, err := i.store.Reserve(c.Request.Context(), record, i.ttl, i.stale)
+		if err != nil {
+			helpers.AbortWithError(c, err)
+
+			return
+		}
+
+		if existing != nil {
+			replay(c, record, *existing)
+
+			return
+		}
+
+		i.run(c, record)
+	}
+}
+
+// run lets the request through and stores its response. The response is
+// kept or the key released even when the request timed out.
+func (i *Idempotency) run(c *gin.Context, record models.IdempotencyRecord) {
+	ctx := context.WithoutCancel(c.Request.Context())
+	done := false
+
+	defer func() {
+		// a panicking handler is answered with 500 further up
+		if !done {
+			i.release(ctx, record)
+		}
+	}()
+
+	writer := &recordingWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+
+	c.Next()
+
+	// answer recorded errors here, so that they are stored like any other
+	// response
+	if len(c.Errors) > 0 && !writer.Written() {
+		helpers.AbortWithError(c, c.Errors.Last().Err)
+	}
+
+	done = true
+
+	if !writer.Written() || writer.Status() >= http.StatusInternalServerError {
+		i.release(ctx, record)
+
+		return
+	}
+
+	record.Status = null.IntFrom(int64(writer.Status()))
+	record.ContentType = null.StringFrom(writer.Header().Get("Content-Type"))
+	record.Body = writer.body.Bytes()
+
+	if err := i.store.Complete(ctx, record); err != nil {
+		logger.Error(c, "unable to store idempotent response", logger.Z{"error": err.Error(), "key": record.Key})
+	}
+}
+
+func (i *Idempotency) release(ctx context.Context, record models.IdempotencyRecord) {
+	if err := i.store.Release(ctx, record.Scope, record.Key); err != nil {
+		logger.Error(ctx, "unable to release idempotency key", logger.Z{"error": err.Error(), "key": record.Key})
+	}
+}
+
+// Purge deletes expired keys every interval until ctx is done.
+func (i *Idempotency) Purge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				purged, err := i.store.PurgeExpired(ctx, idempotencyPurgeBatch)
+				if err != nil {
+					logger.Error(ctx, "unable to purge idempotency keys", logger.Z{"error": err.Error()})
+				}
+
+				if err != nil || purged < idempotencyPurgeBatch {
+					break
+				}
+			}
+		}
+	}
+}
+
+// replay answers a repeated key with the stored response.
+func replay(c *gin.Context, record models.IdempotencyRecord, existing models.IdempotencyRecord) {
+	if existing.RequestHash != record.RequestHash {
+		helpers.AbortWithError(c, exceptions.NewConflict(IdempotencyKeyHeader+
+			" was already used for another request").WithCode("idempotency_key_reused"))
+
+		return
+	}
+
+	if existing.InFlight() {
+		c.Header("Retry-After", "1")
+		helpers.AbortWithError(c, exceptions.NewConflict("a request with this "+IdempotencyKeyHeader+
+			" is in progress").WithCode("idempotency_key_in_flight"))
+
+		return
+	}
+
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Data(int(existing.Status.Int64), existing.ContentType.String, existing.Body)
+	c.Abort()
+}
+
+// requestHash identifies a request by method, path, query and body.
+func requestHash(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func mutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// recordingWriter keeps a copy of the response body.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+
+	return w.ResponseWriter.WriteString(data)
+}
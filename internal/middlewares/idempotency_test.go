@@ -0,0 +1,136 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"restapi/config"
+	models "restapi/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]models.IdempotencyRecord
+}
+
+func (s *memoryIdempotencyStore) Reserve(_ context.Context, record models.IdempotencyRecord, _ time.Duration, _ time.Duration) (*models.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.records[record.Scope+record.Key]; ok {
+		return &existing, nil
+	}
+
+	s.records[record.Scope+record.Key] = record
+
+	return nil, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(_ context.Context, record models.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[record.Scope+record.Key] = record
+
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(_ context.Context, scope string, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, scope+key)
+
+	return nil
+}
+
+func (s *memoryIdempotencyStore) PurgeExpired(context.Context, int) (int64, error) {
+	return 0, nil
+}
+
+func TestIdempotency_Handle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := &memoryIdempotencyStore{records: make(map[string]models.IdempotencyRecord)}
+	idempotency := NewIdempotency(store, config.Server{IdempotencyTTL: time.Hour})
+
+	calls, status := 0, http.StatusCreated
+	release := make(chan struct{})
+
+	router := gin.New()
+	router.POST("/items", idempotency.Handle(), func(c *gin.Context) {
+		calls++
+
+		if c.Query("slow") != "" {
+			<-release
+		}
+
+		c.JSON(status, gin.H{"Call": calls})
+	})
+
+	send := func(key string, path string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		request.Header.Set(IdempotencyKeyHeader, key)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	first := send("k1", "/items", `{"a":1}`)
+	repeat := send("k1", "/items", `{"a":1}`)
+
+	if calls != 1 || repeat.Code != http.StatusCreated || repeat.Body.String() != first.Body.String() {
+		t.Fatalf("repeat was not replayed: %d calls, %d %s", calls, repeat.Code, repeat.Body)
+	}
+
+	if repeat.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Error("replayed response is not marked")
+	}
+
+	if reused := send("k1", "/items", `{"a":2}`); reused.Code != http.StatusConflict {
+		t.Errorf("reused key with another body: got %d", reused.Code)
+	}
+
+	status = http.StatusInternalServerError
+	send("k2", "/items", `{}`)
+
+	status = http.StatusCreated
+	if retried := send("k2", "/items", `{}`); retried.Code != http.StatusCreated || calls != 3 {
+		t.Errorf("server errors must not be replayed: got %d after %d calls", retried.Code, calls)
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		send("k3", "/items?slow=1", `{}`)
+		close(done)
+	}()
+
+	for {
+		store.mu.Lock()
+		_, reserved := store.records["ip:192.0.2.1k3"]
+		store.mu.Unlock()
+
+		if reserved {
+			break
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	if inFlight := send("k3", "/items?slow=1", `{}`); inFlight.Code != http.StatusConflict {
+		t.Errorf("in flight duplicate: got %d", inFlight.Code)
+	}
+
+	close(release)
+	<-done
+}
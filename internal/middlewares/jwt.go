@@ -0,0 +1,357 @@
+package middlewares
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"restapi/config"
+	"restapi/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWTConfig configures bearer token validation. Secret enables HS256 and
+// JWKSFile enables RS256, at least one of them is required.
+type JWTConfig = config.JWT
+
+// JWTAuthenticator validates "Authorization: Bearer <token>" headers.
+type JWTAuthenticator struct {
+	cfg JWTConfig
+	now func() time.Time
+
+	mu      sync.RWMutex
+	rsaKeys map[string]*rsa.PublicKey
+}
+
+func NewJWTAuthenticator(cfg JWTConfig) (*JWTAuthenticator, error) {
+	if !cfg.Enabled() {
+		return nil, errors.New("jwt authentication needs JWT_SECRET or JWT_JWKS_FILE")
+	}
+
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = "roles"
+	}
+
+	authenticator := &JWTAuthenticator{cfg: cfg, now: time.Now}
+
+	return authenticator, authenticator.ReloadKeys()
+}
+
+// ReloadKeys re-reads the JWKS file, keeping the old keys on error.
+func (a *JWTAuthenticator) ReloadKeys() error {
+	file := a.settings().JWKSFile
+	if file == "" {
+		return nil
+	}
+
+	keys, err := readJWKS(file)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.rsaKeys = keys
+	a.mu.Unlock()
+
+	return nil
+}
+
+// Configure swaps the validation settings and reloads the JWKS file. On
+// error the previous settings stay in place.
+func (a *JWTAuthenticator) Configure(cfg JWTConfig) error {
+	if !cfg.Enabled() {
+		return errors.New("jwt authentication cannot be turned off without a restart")
+	}
+
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = "roles"
+	}
+
+	var keys map[string]*rsa.PublicKey
+
+	if cfg.JWKSFile != "" {
+		var err error
+
+		if keys, err = readJWKS(cfg.JWKSFile); err != nil {
+			return err
+		}
+	}
+
+	a.mu.Lock()
+	a.cfg, a.rsaKeys = cfg, keys
+	a.mu.Unlock()
+
+	return nil
+}
+
+func (a *JWTAuthenticator) settings() JWTConfig {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.cfg
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+func (a *JWTAuthenticator) Authenticate(c *gin.Context) (*Identity, error) {
+	authorization := c.Request.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return nil, errNoCredentials
+	}
+
+	claims, err := a.verify(strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer ")))
+	if err != nil {
+		return nil, helpers.UnauthorizedError(err.Error())
+	}
+
+	subject, _ := claims["sub"].(string)
+
+	return &Identity{
+		Name:   subject,
+		Method: "jwt",
+		Roles:  a.roles(claims),
+	}, nil
+}
+
+func (a *JWTAuthenticator) verify(token string) (map[string]interface{}, error) {
+	cfg := a.settings()
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, errors.New("malformed token header")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+
+	// the algorithm decides which key family is used, a token can never
+	// make us verify an HMAC with RSA key material or the other way round
+	switch header.Alg {
+	case "HS256":
+		if cfg.Secret == "" {
+			return nil, errors.New("HS256 tokens are not accepted")
+		}
+
+		mac := hmac.New(sha256.New, []byte(cfg.Secret))
+		mac.Write(signed)
+
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return nil, errors.New("invalid token signature")
+		}
+	case "RS256":
+		key, err := a.rsaKey(header.Kid)
+		if err != nil {
+			return nil, err
+		}
+
+		digest := sha256.Sum256(signed)
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return nil, errors.New("invalid token signature")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported token algorithm %q", header.Alg)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, errors.New("malformed token claims")
+	}
+
+	return claims, a.validateClaims(claims)
+}
+
+func (a *JWTAuthenticator) rsaKey(kid string) (*rsa.PublicKey, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if len(a.rsaKeys) == 0 {
+		return nil, errors.New("RS256 tokens are not accepted")
+	}
+
+	if key, ok := a.rsaKeys[kid]; ok {
+		return key, nil
+	}
+
+	// tokens without a kid are fine as long as the key set is unambiguous
+	if kid == "" && len(a.rsaKeys) == 1 {
+		for _, key := range a.rsaKeys {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (a *JWTAuthenticator) validateClaims(claims map[string]interface{}) error {
+	cfg := a.settings()
+
+	now := a.now()
+
+	exp, ok := numericClaim(claims, "exp")
+	if !ok {
+		return errors.New("token has no expiry")
+	}
+
+	if now.After(exp.Add(cfg.Leeway)) {
+		return errors.New("token has expired")
+	}
+
+	if nbf, ok := numericClaim(claims, "nbf"); ok && now.Add(cfg.Leeway).Before(nbf) {
+		return errors.New("token is not valid yet")
+	}
+
+	if cfg.Issuer != "" {
+		if issuer, _ := claims["iss"].(string); issuer != cfg.Issuer {
+			return errors.New("invalid token issuer")
+		}
+	}
+
+	if cfg.Audience != "" && !containsString(stringsClaim(claims["aud"]), cfg.Audience) {
+		return errors.New("invalid token audience")
+	}
+
+	// the subject names the caller for rate limits and idempotency keys,
+	// tokens without one would all share them
+	if subject, _ := claims["sub"].(string); strings.TrimSpace(subject) == "" {
+		return errors.New("token has no subject")
+	}
+
+	return nil
+}
+
+// roles merges the configured roles claim with the space separated OAuth
+// scope claim.
+func (a *JWTAuthenticator) roles(claims map[string]interface{}) []string {
+	cfg := a.settings()
+
+	roles := stringsClaim(claims[cfg.RolesClaim])
+
+	if scope, ok := claims["scope"].(string); ok {
+		roles = append(roles, strings.Fields(scope)...)
+	}
+
+	return roles
+}
+
+func decodeSegment(segment string, container interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, container)
+}
+
+func numericClaim(claims map[string]interface{}, name string) (time.Time, bool) {
+	value, ok := claims[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return time.Unix(int64(value), 0), true
+}
+
+// stringsClaim accepts both a single string and an array of strings, which
+// is how aud and most role claims are encoded in the wild.
+func stringsClaim(value interface{}) []string {
+	switch typed := value.(type) {
+	case string:
+		return strings.Fields(typed)
+	case []interface{}:
+		result := make([]string, 0, len(typed))
+
+		for _, item := range typed {
+			if str, ok := item.(string); ok {
+				result = append(result, str)
+			}
+		}
+
+		return result
+	default:
+		return nil
+	}
+}
+
+func containsString(values []string, want string) bool {
+	for _, value := range values {
+		if value == want {
+			return true
+		}
+	}
+
+	return false
+}
+
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+func readJWKS(file string) (map[string]*rsa.PublicKey, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var set jwks
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("invalid jwks file %s: %w", file, err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+
+	for _, key := range set.Keys {
+		if key.Kty != "RSA" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus for key %q: %w", key.Kid, err)
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent for key %q: %w", key.Kid, err)
+		}
+
+		keys[key.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("jwks file %s has no RSA signing keys", file)
+	}
+
+	return keys, nil
+}
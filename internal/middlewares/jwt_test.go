@@ -0,0 +1,115 @@
+package middlewares
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func encodeSegment(t *testing.T, value interface{}) string {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func TestJWTAuthenticator_verify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &JWTAuthenticator{
+		cfg: JWTConfig{
+			Secret:     "shared",
+			Audience:   "restapi",
+			Issuer:     "auth.local",
+			RolesClaim: "roles",
+		},
+		now:     func() time.Time { return now },
+		rsaKeys: map[string]*rsa.PublicKey{"k1": &rsaKey.PublicKey},
+	}
+
+	hs256 := func(header map[string]interface{}, claims map[string]interface{}) string {
+		signed := encodeSegment(t, header) + "." + encodeSegment(t, claims)
+		mac := hmac.New(sha256.New, []byte("shared"))
+		mac.Write([]byte(signed))
+
+		return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	rs256 := func(kid string, claims map[string]interface{}) string {
+		signed := encodeSegment(t, map[string]interface{}{"alg": "RS256", "kid": kid}) + "." + encodeSegment(t, claims)
+		digest := sha256.Sum256([]byte(signed))
+
+		signature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+	}
+
+	valid := map[string]interface{}{
+		"sub":   "frontend",
+		"iss":   "auth.local",
+		"aud":   []string{"other", "restapi"},
+		"exp":   now.Add(time.Minute).Unix(),
+		"roles": []string{"read:transactions"},
+		"scope": "write:transactions",
+	}
+
+	with := func(key string, value interface{}) map[string]interface{} {
+		claims := make(map[string]interface{})
+		for k, v := range valid {
+			claims[k] = v
+		}
+		claims[key] = value
+
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "HS256", token: hs256(map[string]interface{}{"alg": "HS256"}, valid)},
+		{name: "RS256", token: rs256("k1", valid)},
+		{name: "RS256 unknown kid", token: rs256("k2", valid), wantErr: true},
+		{name: "alg none", token: hs256(map[string]interface{}{"alg": "none"}, valid), wantErr: true},
+		{name: "expired", token: hs256(map[string]interface{}{"alg": "HS256"}, with("exp", now.Add(-time.Minute).Unix())), wantErr: true},
+		{name: "not before", token: hs256(map[string]interface{}{"alg": "HS256"}, with("nbf", now.Add(time.Minute).Unix())), wantErr: true},
+		{name: "wrong audience", token: hs256(map[string]interface{}{"alg": "HS256"}, with("aud", "other")), wantErr: true},
+		{name: "wrong issuer", token: hs256(map[string]interface{}{"alg": "HS256"}, with("iss", "evil")), wantErr: true},
+		{name: "no subject", token: hs256(map[string]interface{}{"alg": "HS256"}, with("sub", nil)), wantErr: true},
+		{name: "empty subject", token: hs256(map[string]interface{}{"alg": "HS256"}, with("sub", "")), wantErr: true},
+		{name: "malformed", token: "abc.def", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		claims, err := auth.verify(tt.token)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Got: %v, wantErr: %v", tt.name, err, tt.wantErr)
+
+			continue
+		}
+
+		if err == nil {
+			identity := &Identity{Roles: auth.roles(claims)}
+			if !identity.HasRole(RoleReadTransactions) || !identity.HasRole(RoleWriteTransactions) {
+				t.Errorf("%s: roles were not mapped, Got: %v", tt.name, identity.Roles)
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package middlewares
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"restapi/config"
+	"restapi/helpers"
+	"restapi/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxIdleBuckets bounds the memory held for callers that went quiet, full
+// buckets behave like new ones and are dropped past this size.
+const maxIdleBuckets = 10000
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// RateLimiter gives every caller a token bucket, see config.RateLimit.
+type RateLimiter struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	cfg     config.RateLimit
+	buckets map[string]*bucket
+}
+
+func NewRateLimiter(cfg config.RateLimit) *RateLimiter {
+	limiter := &RateLimiter{now: time.Now, buckets: make(map[string]*bucket)}
+	limiter.Configure(cfg)
+
+	return limiter
+}
+
+// Configure changes the limits, callers keep the tokens they have left.
+// Without a burst a caller may spend one second worth of requests at once.
+func (l *RateLimiter) Configure(cfg config.RateLimit) {
+	if cfg.Burst <= 0 {
+		cfg.Burst = int(math.Max(1, math.Ceil(cfg.PerSecond)))
+	}
+
+	l.mu.Lock()
+	l.cfg = cfg
+	l.mu.Unlock()
+}
+
+// Allow takes one token from the bucket of key. When none is left it
+// returns how long the caller should wait.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.cfg.PerSecond <= 0 {
+		return true, 0
+	}
+
+	now := l.now()
+	burst := float64(l.cfg.Burst)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxIdleBuckets {
+			l.dropFullBuckets(now)
+		}
+
+		b = &bucket{tokens: burst, updated: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.updated).Seconds()*l.cfg.PerSecond)
+	b.updated = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.cfg.PerSecond * float64(time.Second))
+	}
+
+	b.tokens--
+
+	return true, 0
+}
+
+func (l *RateLimiter) dropFullBuckets(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*l.cfg.PerSecond >= float64(l.cfg.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Limit rejects requests over the limit with 429 and a Retry-After header.
+// Callers are told apart by identity, so it must run after the auth
+// middlewares, anonymous requests by client ip.
+func (l *RateLimiter) Limit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, wait := l.Allow(callerKey(c))
+		if !allowed {
+			logger.Error(c, "rate limited", logger.Z{"path": c.FullPath()})
+
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			abortWithError(c, helpers.TooManyRequestsError("too many requests"))
+
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// callerKey tells callers apart by identity, anonymous ones by client ip.
+func callerKey(c *gin.Context) string {
+	identity := GetIdentity(c)
+	if identity == nil {
+		return "ip:" + c.ClientIP()
+	}
+
+	// signed and plain requests of one api key are the same caller
+	if identity.Method == "jwt" {
+		return "jwt:" + identity.Name
+	}
+
+	return "key:" + identity.Name
+}
@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"testing"
+	"time"
+
+	"restapi/config"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	limiter := NewRateLimiter(config.RateLimit{PerSecond: 2, Burst: 3})
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.Allow("a"); !ok {
+			t.Fatalf("request %d within the burst was limited", i)
+		}
+	}
+
+	ok, wait := limiter.Allow("a")
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("expected a 500ms wait, got %v %v", ok, wait)
+	}
+
+	if ok, _ := limiter.Allow("b"); !ok {
+		t.Error("callers must not share buckets")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+
+	if ok, _ := limiter.Allow("a"); !ok {
+		t.Error("bucket was not refilled")
+	}
+
+	limiter.Configure(config.RateLimit{})
+
+	for i := 0; i < 10; i++ {
+		if ok, _ := limiter.Allow("a"); !ok {
+			t.Fatal("a disabled limiter must allow everything")
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"restapi/helpers"
+	"restapi/logger"
+)
+
+const (
+	// RoleAll grants every role, a key file has to list it explicitly.
+	RoleAll = "*"
+
+	RoleReadTransactions  = "read:transactions"
+	RoleWriteTransactions = "write:transactions"
+	RoleReadRules         = "read:rules"
+	RoleWriteRules        = "write:rules"
+	RoleAdmin             = "admin"
+)
+
+// defaultKeyRoles are the roles of internal api keys that list none.
+func defaultKeyRoles() []string {
+	return []string{RoleReadTransactions, RoleWriteTransactions}
+}
+
+// HasRole reports whether the caller was granted role.
+func (identity *Identity) HasRole(role string) bool {
+	return containsString(identity.Roles, RoleAll) || containsString(identity.Roles, role)
+}
+
+// RequireRoles lets the request through only when the authenticated caller
+// holds every one of roles. It must run after one of the auth middlewares.
+func RequireRoles(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity := GetIdentity(c)
+		if identity == nil {
+			abortWithError(c, helpers.UnauthorizedError("authentication required"))
+
+			return
+		}
+
+		for _, role := range roles {
+			if !identity.HasRole(role) {
+				logger.Error(c, "access forbidden", logger.Z{
+					"missingRole": role,
+					"path":        c.FullPath(),
+				})
+
+				abortWithError(c, helpers.ForbiddenError("missing role "+role))
+
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,171 @@
+package middlewares
+
+import (
+	"bytes"
+	"container/heap"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"restapi/exceptions"
+)
+
+const (
+	timestampHeader = "X-Timestamp"
+	signatureHeader = "X-Signature"
+
+	maxSignedBodyBytes = 10 << 20
+)
+
+var (
+	errMissingTimestamp = errors.New("missing or invalid " + timestampHeader + " header")
+	errStaleTimestamp   = errors.New("request timestamp outside of the allowed window")
+	errBadSignature     = errors.New("invalid request signature")
+	errReplayed         = errors.New("request signature already used")
+)
+
+// signatureVerifier checks HMAC-SHA256 signatures over
+//
+//	METHOD \n PATH \n TIMESTAMP \n hex(sha256(BODY))
+//
+// and remembers every accepted signature until its timestamp leaves the
+// allowed window, so a captured request cannot be sent twice.
+type signatureVerifier struct {
+	// maxSkew holds a time.Duration, it can change while requests are verified
+	maxSkew atomic.Int64
+	now     func() time.Time
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+	// expiries orders the seen signatures by expiry, so that expired ones
+	// are dropped without scanning seen
+	expiries signatureExpiries
+}
+
+type seenSignature struct {
+	signature string
+	expiry    time.Time
+}
+
+// signatureExpiries is a min-heap of seen signatures by expiry.
+type signatureExpiries []seenSignature
+
+func (e signatureExpiries) Len() int           { return len(e) }
+func (e signatureExpiries) Less(i, j int) bool { return e[i].expiry.Before(e[j].expiry) }
+func (e signatureExpiries) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+
+func (e *signatureExpiries) Push(x interface{}) {
+	*e = append(*e, x.(seenSignature))
+}
+
+func (e *signatureExpiries) Pop() interface{} {
+	old := *e
+	last := old[len(old)-1]
+	*e = old[:len(old)-1]
+
+	return last
+}
+
+func newSignatureVerifier(maxSkew time.Duration) *signatureVerifier {
+	v := &signatureVerifier{
+		now:  time.Now,
+		seen: make(map[string]time.Time),
+	}
+
+	v.setMaxSkew(maxSkew)
+
+	return v
+}
+
+func (v *signatureVerifier) setMaxSkew(maxSkew time.Duration) {
+	v.maxSkew.Store(int64(maxSkew))
+}
+
+// Sign returns the hex signature a client should send for the request.
+func Sign(secret string, method string, path string, timestamp string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(method + "\n" + path + "\n" + timestamp + "\n" + hex.EncodeToString(bodyHash[:])))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (v *signatureVerifier) Verify(r *http.Request, secret string) error {
+	timestamp := r.Header.Get(timestampHeader)
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errMissingTimestamp
+	}
+
+	now := v.now()
+	signedAt := time.Unix(unix, 0)
+	maxSkew := time.Duration(v.maxSkew.Load())
+
+	if signedAt.Before(now.Add(-maxSkew)) || signedAt.After(now.Add(maxSkew)) {
+		return errStaleTimestamp
+	}
+
+	body, err := readAndRestoreBody(r)
+	if err != nil {
+		return err
+	}
+
+	expected := Sign(secret, r.Method, r.URL.RequestURI(), timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(r.Header.Get(signatureHeader))) {
+		return errBadSignature
+	}
+
+	return v.remember(expected, signedAt.Add(maxSkew), now)
+}
+
+func (v *signatureVerifier) remember(signature string, expiry time.Time, now time.Time) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for len(v.expiries) > 0 && v.expiries[0].expiry.Before(now) {
+		expired := heap.Pop(&v.expiries).(seenSignature)
+		delete(v.seen, expired.signature)
+	}
+
+	if _, ok := v.seen[signature]; ok {
+		return errReplayed
+	}
+
+	v.seen[signature] = expiry
+	heap.Push(&v.expiries, seenSignature{signature: signature, expiry: expiry})
+
+	return nil
+}
+
+// readAndRestoreBody reads the body for hashing and puts an identical reader
+// back so that handlers can still bind it. Bodies over maxSignedBodyBytes
+// are rejected rather than hashed in part.
+func readAndRestoreBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSignedBodyBytes+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(body) > maxSignedBodyBytes {
+		return nil, exceptions.NewTooLarge(fmt.Sprintf("request body exceeds %d bytes", maxSignedBodyBytes)).WithCode("body_too_large")
+	}
+
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
@@ -0,0 +1,82 @@
+package middlewares
+
+import (
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"restapi/exceptions"
+)
+
+func TestSignatureVerifier_Verify(t *testing.T) {
+	const secret = "s3cr3t"
+
+	now := time.Unix(1700000000, 0)
+	verifier := newSignatureVerifier(time.Minute)
+	verifier.now = func() time.Time { return now }
+
+	tests := []struct {
+		name     string
+		signedAt time.Time
+		secret   string
+		want     error
+	}{
+		{name: "valid", signedAt: now, secret: secret, want: nil},
+		{name: "replayed", signedAt: now, secret: secret, want: errReplayed},
+		{name: "wrong secret", signedAt: now.Add(time.Second), secret: "other", want: errBadSignature},
+		{name: "stale", signedAt: now.Add(-2 * time.Minute), secret: secret, want: errStaleTimestamp},
+	}
+
+	for _, tt := range tests {
+		body := `{"Code":"abc"}`
+		timestamp := strconv.FormatInt(tt.signedAt.Unix(), 10)
+
+		req := httptest.NewRequest("POST", "/api/v1/transaction?x=1", strings.NewReader(body))
+		req.Header.Set(timestampHeader, timestamp)
+		req.Header.Set(signatureHeader, Sign(tt.secret, "POST", "/api/v1/transaction?x=1", timestamp, []byte(body)))
+
+		if err := verifier.Verify(req, secret); err != tt.want {
+			t.Errorf("%s: Got: %v Want: %v", tt.name, err, tt.want)
+		}
+
+		restored, _ := io.ReadAll(req.Body)
+		if string(restored) != body {
+			t.Errorf("%s: body was not restored, Got: %s", tt.name, restored)
+		}
+	}
+}
+
+func TestSignatureVerifier_remember(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	verifier := newSignatureVerifier(time.Minute)
+
+	for i, signature := range []string{"c", "a", "b"} {
+		if err := verifier.remember(signature, now.Add(time.Duration(3-i)*time.Second), now); err != nil {
+			t.Fatalf("%s: %v", signature, err)
+		}
+	}
+
+	if err := verifier.remember("a", now.Add(time.Minute), now); err != errReplayed {
+		t.Errorf("Got: %v Want: %v", err, errReplayed)
+	}
+
+	// a and b expired, c is still seen
+	if err := verifier.remember("a", now.Add(time.Minute), now.Add(2500*time.Millisecond)); err != nil {
+		t.Errorf("expired signature was not forgotten: %v", err)
+	}
+
+	if len(verifier.seen) != 2 || len(verifier.expiries) != 2 {
+		t.Errorf("Got %d seen and %d expiries, Want 2", len(verifier.seen), len(verifier.expiries))
+	}
+}
+
+func TestReadAndRestoreBody_TooLarge(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/v1/transaction", strings.NewReader(strings.Repeat("x", maxSignedBodyBytes+1)))
+
+	if _, err := readAndRestoreBody(req); !exceptions.Is(err, exceptions.TooLarge) {
+		t.Errorf("Got: %v Want: a TooLarge error", err)
+	}
+}
@@ -0,0 +1,23 @@
+package middlewares
+
+import (
+	"context"
+
+	"restapi/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeout gives the request context the deadline configured for its route.
+// Handlers pass c.Request.Context() down to the database, so the queries of
+// a request that runs out of time, or whose client went away, are cancelled.
+func Timeout(cfg config.Server) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), cfg.Timeout(c.Request.Method, c.FullPath()))
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,46 @@
+package middlewares
+
+import (
+	"restapi/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the correlation id in and out of the service.
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLength = 128
+
+// AttachTransactionIDMiddleware reuses the caller's X-Request-ID, or makes a
+// new one, and stores it under logger.TransactionIDKey on both the gin and
+// the request context so that every log line and outgoing message of the
+// request carries the same id. The id is echoed back in the response.
+func AttachTransactionIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Request.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = logger.NewTransactionID()
+		}
+
+		c.Set(logger.TransactionIDKey, id)
+		c.Request = c.Request.WithContext(logger.WithTransactionID(c.Request.Context(), id))
+		c.Header(RequestIDHeader, id)
+
+		c.Next()
+	}
+}
+
+// validRequestID keeps arbitrary client input out of our logs and headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, r := range id {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,20 @@
+package models
+
+import "gopkg.in/guregu/null.v4"
+
+// IdempotencyRecord is the outcome of the first request sent with an
+// Idempotency-Key by a caller. Status is null while that request is in
+// flight.
+type IdempotencyRecord struct {
+	Scope       string      `db:"Scope"`
+	Key         string      `db:"IdempotencyKey"`
+	RequestHash string      `db:"RequestHash"`
+	Status      null.Int    `db:"Status"`
+	ContentType null.String `db:"ContentType"`
+	Body        []byte      `db:"Body"`
+}
+
+// InFlight reports whether the first request has not answered yet.
+func (r IdempotencyRecord) InFlight() bool {
+	return !r.Status.Valid
+}
@@ -1,52 +1,116 @@
 package models
 
 import (
-	_ "context"
-	_ "encoding/json"
+	"context"
+	"encoding/json"
 
-	_ "restapi/logger"
+	"restapi/logger"
+
+	"gopkg.in/guregu/null.v4"
 )
 
+// Transaction is a row of transactions, with the settings of its action
+// when it has one.
 type Transaction struct {
 	TxnId        int32  `db:"txnId"`
 	Code         string `db:"code"`
 	CompanyId    int32  `db:"companyId"`
 	JobprofileId int32  `db:"jobProfileId"`
+	// ActionId and UserId are set for transactions that reward a user for
+	// an action
+	ActionId null.Int    `db:"actionId"`
+	UserId   null.String `db:"userId"`
+
+	// Info and AdditionalInfo are the raw JSON columns of the action,
+	// decoded into InfoJSON and AdditionalInfoJSON by the Unmarshal methods
+	Info               null.String `db:"info"`
+	AdditionalInfo     null.String `db:"additionalInfo"`
+	CurrencyExpression null.String `db:"currencyExpression"`
+	Type               null.String `db:"type"`
 
-	//InfoJSON           Info
-	//AdditionalInfoJSON AdditionalInfo
+	InfoJSON           *Info           `db:"-"`
+	AdditionalInfoJSON *AdditionalInfo `db:"-"`
 }
 
-// func (ac *Action) UnmarshalInfo() {
-// 	err := json.Unmarshal([]byte(ac.Info), &ac.InfoJSON)
-// 	if err != nil {
-// 		logger.Debug(context.Background(), "error in unmarshalling action json info", logger.Z{
-// 			"error": err,
-// 			"data":  ac,
-// 		})
-// 	}
-// }
+// TransactionFilter narrows and orders a transactions listing. Pages are
+// walked with a keyset on (SortBy, txnId) so that deep pages cost the same
+// as the first one.
+type TransactionFilter struct {
+	CompanyId    int32
+	JobprofileId int32
+	CodePrefix   string
+	SortBy       string
+	Descending   bool
+	Limit        int
+	After        *TransactionCursor
+}
 
-// func (ac *Action) UnmarshalAdditionalInfo() {
-// 	err := json.Unmarshal([]byte(ac.AdditionalInfo), &ac.AdditionalInfoJSON)
-// 	if err != nil {
-// 		logger.Debug(context.Background(), "error in unmarshalling action json additional info", logger.Z{
-// 			"error": err,
-// 			"data":  ac,
-// 		})
-// 	}
-// }
+// TransactionCursor points at the last row of the previous page.
+type TransactionCursor struct {
+	SortBy     string      `json:"s"`
+	Descending bool        `json:"d,omitempty"`
+	SortValue  interface{} `json:"v"`
+	TxnId      int32       `json:"id"`
+}
 
-// type Info struct {
-// 	Narration         string `json:"Narration"`
-// 	ModifiedNarration string `json:"ModifiedNarration"`
-// 	EventNarration    string `json:"EventNarration"`
-// }
+// UnmarshalInfo decodes Info, leaving InfoJSON nil when it is empty or
+// malformed.
+func (ac *Transaction) UnmarshalInfo() {
+	ac.InfoJSON = nil
 
-// type AdditionalInfo struct {
-// 	MaxCoins     int `json:"MaxCoins"`
-// 	ValidityDays int `json:"ValidityDays"`
-// }
+	if ac.Info.ValueOrZero() == "" {
+		return
+	}
+
+	var info Info
+
+	err := json.Unmarshal([]byte(ac.Info.String), &info)
+	if err != nil {
+		logger.Debug(context.Background(), "error in unmarshalling action json info", logger.Z{
+			"error": err,
+			"txnId": ac.TxnId,
+		})
+
+		return
+	}
+
+	ac.InfoJSON = &info
+}
+
+// UnmarshalAdditionalInfo decodes AdditionalInfo like UnmarshalInfo does
+// Info.
+func (ac *Transaction) UnmarshalAdditionalInfo() {
+	ac.AdditionalInfoJSON = nil
+
+	if ac.AdditionalInfo.ValueOrZero() == "" {
+		return
+	}
+
+	var additionalInfo AdditionalInfo
+
+	err := json.Unmarshal([]byte(ac.AdditionalInfo.String), &additionalInfo)
+	if err != nil {
+		logger.Debug(context.Background(), "error in unmarshalling action json additional info", logger.Z{
+			"error": err,
+			"txnId": ac.TxnId,
+		})
+
+		return
+	}
+
+	ac.AdditionalInfoJSON = &additionalInfo
+}
+
+type Info struct {
+	Narration         string `json:"Narration"`
+	ModifiedNarration string `json:"ModifiedNarration"`
+	EventNarration    string `json:"EventNarration"`
+}
+
+type AdditionalInfo struct {
+	MaxCoins     int `json:"MaxCoins"`
+	ValidityDays int `json:"ValidityDays"`
+}
 
 // type ActionRewardMapping struct {
 // 	ID       int `db:"Id"`
@@ -54,10 +118,29 @@ type Transaction struct {
 // 	RewardID int `db:"RewardId"`
 // }
 
-// type ActionCurrency struct {
-// 	ID         int    `db:"Id"`
-// 	ActionID   int    `db:"ActionId"`
-// 	Type       string `db:"Type"`
-// 	Info       string `db:"Info"`
-// 	ModifiedBy string `db:"ModifiedBy"`
-// }
+// ActionFrequencyLimit caps how often a user can earn from an action,
+// Limit times in any WindowDays days. It is not limited when either is
+// null.
+type ActionFrequencyLimit struct {
+	ActionID   int      `db:"Id"`
+	Limit      null.Int `db:"FrequencyLimit"`
+	WindowDays null.Int `db:"FrequencyWindowDays"`
+}
+
+// Enabled reports whether the action is limited at all.
+func (l ActionFrequencyLimit) Enabled() bool {
+	return l.Limit.Valid && l.WindowDays.Valid && l.WindowDays.Int64 > 0
+}
+
+// ActionCurrencyRule computes the currency of type Type an action awards,
+// by evaluating Expression and rounding it to Scale decimal places.
+type ActionCurrencyRule struct {
+	ID         int    `db:"Id"`
+	ActionID   int    `db:"ActionId"`
+	Type       string `db:"Type"`
+	Expression string `db:"Expression"`
+	Scale      int    `db:"Scale"`
+	// Info is free form JSON describing the rule
+	Info       string `db:"Info"`
+	ModifiedBy string `db:"ModifiedBy"`
+}
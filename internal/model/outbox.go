@@ -0,0 +1,61 @@
+package models
+
+import (
+	"encoding/json"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// OutboxMessage is a Kafka message waiting in the outbox table. Headers is
+// a JSON object of header names to values.
+type OutboxMessage struct {
+	ID        int64       `db:"Id"`
+	Topic     string      `db:"Topic"`
+	Key       []byte      `db:"MessageKey"`
+	Payload   []byte      `db:"Payload"`
+	Headers   null.String `db:"Headers"`
+	Attempts  int         `db:"Attempts"`
+	LastError null.String `db:"LastError"`
+}
+
+// OutboxKey orders outbox messages: those with the same Topic and Key are
+// published in Id order.
+type OutboxKey struct {
+	Topic string
+	Key   string
+}
+
+// OrderKey is the key m is ordered by, ok is false for messages without a
+// key, which are not ordered.
+func (m OutboxMessage) OrderKey() (OutboxKey, bool) {
+	return OutboxKey{Topic: m.Topic, Key: string(m.Key)}, m.Key != nil
+}
+
+// SetHeaders stores headers as JSON, leaving Headers null when empty.
+func (m *OutboxMessage) SetHeaders(headers map[string]string) error {
+	m.Headers = null.String{}
+
+	if len(headers) == 0 {
+		return nil
+	}
+
+	encoded, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+
+	m.Headers = null.StringFrom(string(encoded))
+
+	return nil
+}
+
+// HeaderMap decodes Headers.
+func (m OutboxMessage) HeaderMap() (map[string]string, error) {
+	headers := make(map[string]string)
+
+	if m.Headers.ValueOrZero() == "" {
+		return headers, nil
+	}
+
+	return headers, json.Unmarshal([]byte(m.Headers.String), &headers)
+}
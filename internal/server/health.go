@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"restapi/cache"
+	"restapi/config"
+	"restapi/db"
+	"restapi/internal/health"
+	"restapi/kafka"
+)
+
+const (
+	mysqlHealthTimeout     = 2 * time.Second
+	aerospikeHealthTimeout = 1 * time.Second
+	kafkaHealthTimeout     = 3 * time.Second
+)
+
+// registerHealthChecks makes MySQL, replicas and master, critical for readiness.
+// Aerospike is checked when CACHE is enabled and the Kafka cluster named by
+// KAFKA_PREFIX when it has brokers configured, both are reported without
+// failing the probe since the API keeps serving without them.
+func registerHealthChecks(checker *health.Checker, replicas *db.ReplicaSet, masterDB *db.DB, aerospike *cache.Aerospike, cluster *config.Kafka) {
+	// reads keep working as long as one replica is left
+	checker.Register(health.Check{
+		Name:     "mysql-replica",
+		Critical: true,
+		Timeout:  mysqlHealthTimeout,
+		Probe:    replicas.Ping,
+	})
+
+	checker.Register(health.Check{
+		Name:     "mysql-master",
+		Critical: true,
+		Timeout:  mysqlHealthTimeout,
+		Probe:    masterDB.Ping,
+	})
+
+	if aerospike != nil {
+		checker.Register(health.Check{
+			Name:    "aerospike",
+			Timeout: aerospikeHealthTimeout,
+			Probe: func(context.Context) error {
+				return aerospike.Ping()
+			},
+		})
+	}
+
+	if cluster != nil && len(cluster.Brokers) > 0 {
+		checker.Register(health.Check{
+			Name:    "kafka",
+			Timeout: kafkaHealthTimeout,
+			Probe: func(ctx context.Context) error {
+				return kafka.PingBrokers(ctx, cluster.Brokers)
+			},
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"restapi/config"
+	"restapi/db"
+	"restapi/internal/middlewares"
+	"restapi/logger"
+)
+
+// reloader re-reads the configuration and applies the settings listed in
+// config.Runtime to the running server. Values set in the process
+// environment win over the files, so only changes to the files can be
+// picked up.
+type reloader struct {
+	mu      sync.Mutex
+	current *config.Config
+
+	replicas   *db.ReplicaSet
+	masterDB   *db.DB
+	apiKeys    *middlewares.APIKeyStore
+	apiKeyAuth *middlewares.APIKeyAuthenticator
+	// jwtAuth is nil when JWT was disabled at startup
+	jwtAuth *middlewares.JWTAuthenticator
+	limiter *middlewares.RateLimiter
+}
+
+func newReloader(cfg *config.Config) *reloader {
+	return &reloader{current: cfg}
+}
+
+// Reload applies the new settings and returns what changed. A config that
+// does not load or validate is rejected as a whole and nil changes are
+// returned; otherwise the changes are returned along with the parts that
+// could not be applied.
+func (r *reloader) Reload(ctx context.Context) ([]config.Change, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := config.Load(r.current.Environment)
+	if err == nil {
+		err = cfg.Validate()
+	}
+
+	if err != nil {
+		logger.Error(ctx, "configuration not reloaded", logger.Z{"error": err.Error()})
+
+		return nil, err
+	}
+
+	changes := config.Diff(r.current, cfg)
+
+	var errs []error
+
+	logger.SetLevel(cfg.Log.Level)
+
+	r.replicas.Resize(cfg.Replica)
+	r.masterDB.Resize(cfg.Master)
+
+	if err := r.apiKeys.Configure(cfg.Auth.APIKeys); err != nil {
+		errs = append(errs, fmt.Errorf("api keys: %w", err))
+	}
+
+	r.apiKeyAuth.SetMaxSkew(cfg.Auth.APIKeys.MaxSkew)
+
+	if r.jwtAuth != nil {
+		if err := r.jwtAuth.Configure(cfg.Auth.JWT); err != nil {
+			errs = append(errs, fmt.Errorf("jwt: %w", err))
+		}
+	} else {
+		for i := range changes {
+			if strings.HasPrefix(changes[i].Name, "JWT_") {
+				changes[i].Restart = true
+			}
+		}
+	}
+
+	r.limiter.Configure(cfg.Auth.RateLimit)
+
+	err = errors.Join(errs...)
+
+	// the next reload diffs against what was applied, a part that failed
+	// is reported as changed again
+	if err == nil {
+		r.current = cfg
+	}
+
+	restart := make([]string, 0)
+	for _, change := range changes {
+		if change.Restart {
+			restart = append(restart, change.Name)
+		}
+	}
+
+	data := logger.Z{"changes": changes, "restartRequired": restart}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+
+	logger.Info(ctx, "configuration reloaded", data)
+
+	return changes, err
+}
+
+// reloadOnHangup reloads on every SIGHUP until ctx is done.
+func (r *reloader) reloadOnHangup(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			// nolint:errcheck
+			r.Reload(ctx)
+		}
+	}
+}
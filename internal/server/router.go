@@ -2,26 +2,36 @@ package server
 
 import (
 	"context"
+	"log"
 	"net/http"
-	"os"
 	"restapi/internal/middlewares"
+	"time"
 
+	"restapi/cache"
+	"restapi/config"
 	"restapi/db"
+	"restapi/internal/health"
 	"restapi/logger"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"restapi/internal/controller/admin"
+	"restapi/internal/controller/rules"
 	"restapi/internal/controller/transaction"
+	"restapi/internal/dao/mysql"
+	transactionService "restapi/internal/service/transaction"
 )
 
-func NewRouter(env string) *gin.Engine {
-	logger.Init("restapi", os.Getenv("LOG_LEVEL"))
+func NewRouter(cfg *config.Config, checker *health.Checker, reloads *reloader) *gin.Engine {
+	logger.Configure("restapi", cfg.Log)
 
 	logger.Debug(context.Background(), "starting server...", logger.Z{
-		"mode": os.Getenv("GIN_MODE"),
+		"mode":    cfg.Server.GinMode,
+		"sources": cfg.Sources,
 	})
 
-	if os.Getenv("GIN_MODE") == "release" {
+	if cfg.Server.GinMode == "release" {
 		gin.SetMode(gin.ReleaseMode)
 	} else {
 		gin.SetMode(gin.DebugMode)
@@ -35,35 +45,119 @@ func NewRouter(env string) *gin.Engine {
 		router.Use(gin.Logger())
 	}
 
-	// do not cache anything by default
-	// router.Use(middlewares.AttachTransactionIDMiddleware())
+	router.Use(middlewares.AttachTransactionIDMiddleware())
+	router.Use(middlewares.Metrics())
+	router.Use(middlewares.Timeout(cfg.Server))
+	router.Use(middlewares.Errors())
 
-	registerRoutes(env, router)
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	router.GET("/livez", checker.Liveness)
+	router.GET("/readyz", checker.Readiness)
+
+	registerRoutes(cfg, router, checker, reloads)
 
 	return router
 }
 
 const (
-	maxOpenConn = 2
-	maxIdleConn = 2
+	apiKeyReloadInterval     = 30 * time.Second
+	idempotencyPurgeInterval = 10 * time.Minute
+	replicaHealthInterval    = 5 * time.Second
 )
 
-func registerRoutes(env string, router *gin.Engine) {
-	mysqlDB := db.Conn(env, true, -1, -1)
-	masterDBHandle := db.Conn(env, false, maxOpenConn, maxIdleConn, "MASTER")
+func registerRoutes(cfg *config.Config, router *gin.Engine, checker *health.Checker, reloads *reloader) {
+	replicas := db.ConnectReplicas(cfg.Replica, -1, -1)
+	masterDBHandle := db.Connect(cfg.Master, -1, -1)
+
+	go replicas.Watch(context.Background(), replicaHealthInterval, mysqlHealthTimeout)
+
+	// a disabled cache misses every read and drops every write
+	store := cache.NewAerospikeCacheFromConfig(cfg.Cache)
+
+	var aerospike *cache.Aerospike
+	if cfg.Cache.Enabled {
+		aerospike = store
+	}
+
+	registerHealthChecks(checker, replicas, masterDBHandle, aerospike, cfg.Kafka)
+
+	transactionController := transaction.NewTransactionController(replicas, masterDBHandle, transactionService.Options{
+		Counts:      store,
+		CountTTL:    cfg.Cache.ActionCountTTL,
+		EventsTopic: cfg.Outbox.TransactionTopic,
+	})
+	rulesController := rules.NewRulesController(replicas, masterDBHandle)
+
+	apiKeys, err := middlewares.NewAPIKeyStore(cfg.Auth.APIKeys)
+	if err != nil {
+		log.Fatalf("unable to load api keys: %s", err)
+	}
+
+	go apiKeys.Watch(context.Background(), apiKeyReloadInterval)
+
+	apiKeyAuth := middlewares.NewAPIKeyAuthenticator(apiKeys, cfg.Auth.APIKeys.MaxSkew)
+	authenticators := []middlewares.Authenticator{apiKeyAuth}
+
+	if cfg.Auth.JWT.Enabled() {
+		jwtAuth, err := middlewares.NewJWTAuthenticator(cfg.Auth.JWT)
+		if err != nil {
+			log.Fatalf("unable to set up jwt authentication: %s", err)
+		}
+
+		authenticators = append(authenticators, jwtAuth)
+		reloads.jwtAuth = jwtAuth
+	}
+
+	limiter := middlewares.NewRateLimiter(cfg.Auth.RateLimit)
+
+	idempotency := middlewares.NewIdempotency(mysql.NewIdempotencyDao(replicas, masterDBHandle), cfg.Server)
+	go idempotency.Purge(context.Background(), idempotencyPurgeInterval)
 
-	transactionController := transaction.NewTransactionController(mysqlDB, masterDBHandle)
+	reloads.replicas, reloads.masterDB = replicas, masterDBHandle
+	reloads.apiKeys, reloads.apiKeyAuth, reloads.limiter = apiKeys, apiKeyAuth, limiter
+
+	adminController := admin.NewAdminController(reloads)
+
+	canRead := middlewares.RequireRoles(middlewares.RoleReadTransactions)
+	canWrite := middlewares.RequireRoles(middlewares.RoleWriteTransactions)
 
 	dopamineGroup := router.Group("api/v1")
 	{
+		// kept for existing monitors, new ones should use /livez and /readyz
 		dopamineGroup.GET("/healthcheck", func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{"Test": "Successful"})
 		})
 
 		actionRoutes := dopamineGroup.Group("transaction")
 		{
-			actionRoutes.GET("/all", middlewares.AuthInternalRoutes(), transactionController.Info)
+			actionRoutes.Use(middlewares.AuthRoutes(authenticators...), limiter.Limit(), idempotency.Handle())
+
+			actionRoutes.GET("/all", canRead, transactionController.Info)
+			actionRoutes.POST("", canWrite, transactionController.Create)
+			actionRoutes.GET("/:id", canRead, transactionController.Get)
+			actionRoutes.PUT("/:id", canWrite, transactionController.Update)
+			actionRoutes.PATCH("/:id", canWrite, transactionController.Patch)
+			actionRoutes.DELETE("/:id", canWrite, transactionController.Delete)
+		}
+
+		ruleRoutes := dopamineGroup.Group("actions")
+		{
+			ruleRoutes.Use(middlewares.AuthRoutes(authenticators...), limiter.Limit(), idempotency.Handle())
+
+			canReadRules := middlewares.RequireRoles(middlewares.RoleReadRules)
+			canWriteRules := middlewares.RequireRoles(middlewares.RoleWriteRules)
+
+			ruleRoutes.GET("/rules/variables", canReadRules, rulesController.Variables)
+			ruleRoutes.GET("/:id/rules", canReadRules, rulesController.List)
+			ruleRoutes.POST("/:id/rules", canWriteRules, rulesController.Add)
+			ruleRoutes.POST("/:id/rules/dry-run", canReadRules, rulesController.DryRun)
+		}
+
+		adminRoutes := dopamineGroup.Group("admin")
+		{
+			adminRoutes.Use(middlewares.AuthRoutes(authenticators...), limiter.Limit())
 
+			adminRoutes.POST("/reload", middlewares.RequireRoles(middlewares.RoleAdmin), adminController.Reload)
 		}
 
 	}
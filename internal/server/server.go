@@ -7,33 +7,44 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"path"
-	"path/filepath"
-	"runtime"
 	"syscall"
 	"time"
 
-	"github.com/joho/godotenv"
+	"restapi/config"
+	"restapi/internal/health"
 )
 
 const timeOut = 5
 
-const apiTimeOut = 150000
-
+// Init loads and validates the settings of the env file, e.g. ".test.env",
+// and runs the server.
 func Init(env string) {
-	_, b, _, _ := runtime.Caller(0)
-	basepath := filepath.Dir(b)
-	ap := path.Join(basepath, "../../config", env)
+	cfg, err := config.Load(env)
+	if err == nil {
+		err = cfg.Validate()
+	}
 
-	if err := godotenv.Load(ap); err != nil {
-		log.Fatalf("%s", err)
+	if err != nil {
+		log.Fatalf("invalid configuration:\n%s", err)
 	}
 
-	r := NewRouter(env)
+	Run(cfg)
+}
+
+// Run serves the API until SIGINT or SIGTERM.
+func Run(cfg *config.Config) {
+	checker := health.NewChecker()
+	reloads := newReloader(cfg)
+
+	r := NewRouter(cfg, checker, reloads)
+
+	go reloads.reloadOnHangup(context.Background())
 
 	srv := &http.Server{
-		Addr:    os.Getenv("SERVER_PORT"),
-		Handler: http.TimeoutHandler(r, apiTimeOut*time.Millisecond, "Timeout!\n"),
+		Addr: cfg.Server.Addr,
+		// a backstop, the route deadlines of middlewares.Timeout expire first
+		// and let the handlers answer themselves
+		Handler: http.TimeoutHandler(r, cfg.Server.MaxTimeout()+timeOut*time.Second, "Timeout!\n"),
 	}
 
 	go func() {
@@ -53,6 +64,13 @@ func Init(env string) {
 	<-quit
 	log.Println("Shutdown Server...")
 
+	checker.SetReady(false)
+
+	// keep serving with a failing readiness probe for a while, so that load
+	// balancers notice and stop routing here before connections are refused
+	log.Printf("Draining for %s...\n", cfg.Server.ShutdownDrain)
+	time.Sleep(cfg.Server.ShutdownDrain)
+
 	ctx, cancel := context.WithTimeout(context.Background(), timeOut*time.Second)
 	defer cancel()
 
@@ -60,5 +78,9 @@ func Init(env string) {
 		log.Fatal("Server Shutdown: ", err)
 	}
 
+	if err := errors.Join(reloads.replicas.Close(), reloads.masterDB.Close()); err != nil {
+		log.Println("Closing databases: ", err)
+	}
+
 	log.Println("Server exiting")
 }
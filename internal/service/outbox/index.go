@@ -0,0 +1,54 @@
+package outbox
+
+import (
+	"sync"
+	"time"
+
+	"restapi/config"
+	"restapi/internal/dao/mysql"
+	models "restapi/internal/model"
+
+	"restapi/db"
+
+	"github.com/IBM/sarama"
+)
+
+// Sender publishes one message synchronously, like kafka.Producer.
+type Sender interface {
+	SendMessage(message *sarama.ProducerMessage) (int32, int64, error)
+}
+
+// Relay publishes the outbox table to Kafka. Messages are published at
+// least once and, as long as the producer partitions by key, in order for
+// each key.
+type Relay struct {
+	outboxDao *mysql.OutboxDao
+	sender    Sender
+	cfg       config.Outbox
+
+	mu sync.Mutex
+	// waiting are the keys whose oldest message failed, until their retry
+	// is due
+	waiting map[models.OutboxKey]time.Time
+}
+
+func NewRelay(replicas *db.ReplicaSet,
+	masterDB *db.DB,
+	sender Sender,
+	cfg config.Outbox,
+) *Relay {
+	if replicas == nil || masterDB == nil {
+		panic("db cannot be null")
+	}
+
+	if sender == nil {
+		panic("sender cannot be null")
+	}
+
+	return &Relay{
+		outboxDao: mysql.NewOutboxDao(replicas, masterDB),
+		sender:    sender,
+		cfg:       cfg,
+		waiting:   make(map[models.OutboxKey]time.Time),
+	}
+}
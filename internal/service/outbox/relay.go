@@ -0,0 +1,192 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	models "restapi/internal/model"
+	"restapi/logger"
+
+	"github.com/IBM/sarama"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	purgeInterval = time.Hour
+	purgeBatch    = 1000
+
+	// maxRetryDelay bounds the wait of a key whose oldest message keeps
+	// failing, and maxWaitingKeys how many of those a query leaves out
+	maxRetryDelay  = time.Minute
+	maxWaitingKeys = 1000
+)
+
+// Run polls the outbox every PollInterval, draining it a batch at a time,
+// and purges sent messages older than Retention every hour, until ctx is
+// done.
+func (r *Relay) Run(ctx context.Context) {
+	poll := time.NewTicker(r.cfg.PollInterval)
+	defer poll.Stop()
+
+	purge := time.NewTicker(purgeInterval)
+	defer purge.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-poll.C:
+			for ctx.Err() == nil {
+				sent, err := r.Publish(ctx)
+				if err != nil {
+					logger.Error(ctx, "unable to relay the outbox", logger.Z{"error": err.Error()})
+				}
+
+				if err != nil || sent < r.cfg.BatchSize {
+					break
+				}
+			}
+		case <-purge.C:
+			r.Purge(ctx)
+		}
+	}
+}
+
+// Publish sends one batch of pending messages and returns how many were
+// sent. A message that fails is retried after a backoff, from PollInterval
+// up to a minute, and the later messages with its key wait for it. Other
+// keys are published meanwhile. The batch stays locked while it is sent,
+// and the transaction is not retried: a deadlock would publish it again.
+func (r *Relay) Publish(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var sent []int64
+
+	skip := r.waitingKeys(time.Now())
+
+	err := r.outboxDao.TransactionOnce(ctx, nil, func(ctx context.Context, tx *sqlx.Tx) error {
+		messages, err := r.outboxDao.LockPending(ctx, tx, r.cfg.BatchSize, skip)
+		if err != nil {
+			return err
+		}
+
+		sent = make([]int64, 0, len(messages))
+		failed := make(map[int64]error)
+
+		for _, message := range messages {
+			key, ordered := message.OrderKey()
+			if _, waiting := r.waiting[key]; ordered && waiting {
+				continue
+			}
+
+			if err := r.send(message); err != nil {
+				logger.Error(ctx, "unable to publish outbox message", logger.Z{
+					"error":    err.Error(),
+					"id":       message.ID,
+					"topic":    message.Topic,
+					"attempts": message.Attempts + 1,
+				})
+
+				// messages without a key are not ordered
+				if ordered {
+					r.waiting[key] = time.Now().Add(r.retryDelay(message.Attempts + 1))
+				}
+
+				failed[message.ID] = err
+
+				continue
+			}
+
+			sent = append(sent, message.ID)
+		}
+
+		if err := r.outboxDao.MarkSent(ctx, tx, sent); err != nil {
+			return err
+		}
+
+		// the attempts are for diagnosis only, failing to count them must
+		// not undo MarkSent and publish the sent messages again
+		for id, cause := range failed {
+			if err := r.outboxDao.MarkFailed(ctx, tx, id, cause); err != nil {
+				logger.Error(ctx, "unable to record the failed attempt", logger.Z{"error": err.Error(), "id": id})
+			}
+		}
+
+		return nil
+	})
+
+	return len(sent), err
+}
+
+// waitingKeys forgets the keys that are due for a retry and returns the
+// others, which the next batch leaves out.
+func (r *Relay) waitingKeys(now time.Time) []models.OutboxKey {
+	keys := make([]models.OutboxKey, 0, len(r.waiting))
+
+	for key, due := range r.waiting {
+		if !now.Before(due) {
+			delete(r.waiting, key)
+		} else if len(keys) < maxWaitingKeys {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// retryDelay doubles PollInterval with every failed attempt.
+func (r *Relay) retryDelay(attempts int) time.Duration {
+	delay := r.cfg.PollInterval
+
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+
+	return delay
+}
+
+func (r *Relay) send(message models.OutboxMessage) error {
+	headers, err := message.HeaderMap()
+	if err != nil {
+		return err
+	}
+
+	producerMessage := &sarama.ProducerMessage{
+		Topic: message.Topic,
+		Value: sarama.ByteEncoder(message.Payload),
+	}
+
+	if message.Key != nil {
+		producerMessage.Key = sarama.ByteEncoder(message.Key)
+	}
+
+	for name, value := range headers {
+		producerMessage.Headers = append(producerMessage.Headers, sarama.RecordHeader{
+			Key:   []byte(name),
+			Value: []byte(value),
+		})
+	}
+
+	_, _, err = r.sender.SendMessage(producerMessage)
+
+	return err
+}
+
+// Purge deletes the messages sent more than Retention ago.
+func (r *Relay) Purge(ctx context.Context) {
+	for ctx.Err() == nil {
+		purged, err := r.outboxDao.PurgeSent(ctx, r.cfg.Retention, purgeBatch)
+		if err != nil {
+			logger.Error(ctx, "unable to purge the outbox", logger.Z{"error": err.Error()})
+		}
+
+		if err != nil || purged < purgeBatch {
+			return
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package outbox
+
+import (
+	"testing"
+	"time"
+
+	"restapi/config"
+	models "restapi/internal/model"
+)
+
+func TestRelay_retryDelay(t *testing.T) {
+	relay := &Relay{cfg: config.Outbox{PollInterval: time.Second}}
+
+	cases := map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		4:  8 * time.Second,
+		7:  maxRetryDelay,
+		50: maxRetryDelay,
+	}
+
+	for attempts, want := range cases {
+		if got := relay.retryDelay(attempts); got != want {
+			t.Errorf("after %d attempts got %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestRelay_waitingKeys(t *testing.T) {
+	now := time.Now()
+
+	due := models.OutboxKey{Topic: "events", Key: "company-1"}
+	waiting := models.OutboxKey{Topic: "events", Key: "company-2"}
+
+	relay := &Relay{waiting: map[models.OutboxKey]time.Time{
+		due:     now.Add(-time.Second),
+		waiting: now.Add(time.Minute),
+	}}
+
+	keys := relay.waitingKeys(now)
+
+	if len(keys) != 1 || keys[0] != waiting {
+		t.Errorf("got %v, want only %v", keys, waiting)
+	}
+
+	if _, ok := relay.waiting[due]; ok {
+		t.Error("a key due for a retry is still waiting")
+	}
+}
@@ -0,0 +1,289 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"restapi/exceptions"
+
+	"github.com/Knetic/govaluate"
+)
+
+// MaxScale bounds the decimal places of a result.
+const MaxScale = 6
+
+// VariableType says how the value of a variable is read from a payload.
+type VariableType string
+
+const (
+	Number VariableType = "number"
+	String VariableType = "string"
+	Bool   VariableType = "bool"
+	// Date values are RFC 3339 timestamps or 2006-01-02 dates, expressions
+	// see them as unix seconds
+	Date VariableType = "date"
+)
+
+// Variable is a name expressions may refer to.
+type Variable struct {
+	Name        string       `json:"Name"`
+	Type        VariableType `json:"Type"`
+	Description string       `json:"Description"`
+}
+
+// Result is the value of an expression rounded to its scale, Value is the
+// same number formatted with exactly that many decimals.
+type Result struct {
+	Number float64 `json:"Number"`
+	Value  string  `json:"Value"`
+}
+
+// Engine compiles and evaluates currency expressions. Compiled expressions
+// are cached by their text, so a rule is parsed once however often it runs.
+type Engine struct {
+	variables map[string]Variable
+	functions map[string]govaluate.ExpressionFunction
+	now       func() time.Time
+
+	mu       sync.RWMutex
+	compiled map[string]*govaluate.EvaluableExpression
+}
+
+func NewEngine(variables ...Variable) *Engine {
+	engine := &Engine{
+		variables: make(map[string]Variable, len(variables)),
+		now:       time.Now,
+		compiled:  make(map[string]*govaluate.EvaluableExpression),
+	}
+
+	for _, variable := range variables {
+		engine.variables[variable.Name] = variable
+	}
+
+	engine.functions = map[string]govaluate.ExpressionFunction{
+		"min":   numbers("min", 1, -1, func(args []float64) float64 { return fold(args, math.Min) }),
+		"max":   numbers("max", 1, -1, func(args []float64) float64 { return fold(args, math.Max) }),
+		"clamp": numbers("clamp", 3, 3, func(args []float64) float64 { return math.Max(args[1], math.Min(args[0], args[2])) }),
+		"abs":   numbers("abs", 1, 1, func(args []float64) float64 { return math.Abs(args[0]) }),
+		"floor": numbers("floor", 1, 1, func(args []float64) float64 { return math.Floor(args[0]) }),
+		"ceil":  numbers("ceil", 1, 1, func(args []float64) float64 { return math.Ceil(args[0]) }),
+		"round": numbers("round", 1, 2, func(args []float64) float64 {
+			if len(args) == 1 {
+				return math.Round(args[0])
+			}
+
+			return round(args[0], int(args[1]))
+		}),
+		// days_since(date) is the number of whole days from date to now
+		"days_since": numbers("days_since", 1, 1, func(args []float64) float64 {
+			return math.Floor(float64(engine.now().Unix()-int64(args[0])) / (24 * 60 * 60))
+		}),
+		"now": func(arguments ...interface{}) (interface{}, error) {
+			return float64(engine.now().Unix()), nil
+		},
+	}
+
+	return engine
+}
+
+// Variables lists the variables expressions may use, by name.
+func (e *Engine) Variables() []Variable {
+	variables := make([]Variable, 0, len(e.variables))
+	for _, variable := range e.variables {
+		variables = append(variables, variable)
+	}
+
+	sort.Slice(variables, func(i, j int) bool { return variables[i].Name < variables[j].Name })
+
+	return variables
+}
+
+// Compile parses expression and checks that it only uses known variables
+// and functions, returning an exceptions.Validation otherwise. The result is
+// kept for Evaluate, rules are compiled when they are saved or loaded.
+func (e *Engine) Compile(expression string) error {
+	compiled, err := e.compile(expression)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.compiled[expression] = compiled
+	e.mu.Unlock()
+
+	return nil
+}
+
+// compile returns the cached expression, or parses it without caching it,
+// so that dry runs of arbitrary expressions do not grow the cache.
+func (e *Engine) compile(expression string) (*govaluate.EvaluableExpression, error) {
+	e.mu.RLock()
+	compiled, ok := e.compiled[expression]
+	e.mu.RUnlock()
+
+	if ok {
+		return compiled, nil
+	}
+
+	if strings.TrimSpace(expression) == "" {
+		return nil, invalid("required", "is required")
+	}
+
+	compiled, err := govaluate.NewEvaluableExpressionWithFunctions(expression, e.functions)
+	if err != nil {
+		return nil, invalid("expression", err.Error())
+	}
+
+	var unknown []string
+
+	for _, name := range compiled.Vars() {
+		if _, ok := e.variables[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+
+	if len(unknown) > 0 {
+		return nil, invalid("variables", "unknown variables "+strings.Join(unknown, ", "))
+	}
+
+	return compiled, nil
+}
+
+// Evaluate runs expression with the variables in payload and rounds the
+// result to scale decimal places. Variables the expression uses must be in
+// payload.
+func (e *Engine) Evaluate(expression string, scale int, payload map[string]interface{}) (*Result, error) {
+	compiled, err := e.compile(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	parameters := make(map[string]interface{}, len(payload))
+
+	var violations []exceptions.FieldError
+
+	for _, name := range compiled.Vars() {
+		if payload[name] == nil {
+			violations = append(violations, exceptions.FieldError{Field: "Variables." + name, Rule: "required", Message: "is required"})
+
+			continue
+		}
+
+		value, err := e.read(name, payload[name])
+		if err != nil {
+			violations = append(violations, exceptions.FieldError{Field: "Variables." + name, Rule: "type", Message: err.Error()})
+
+			continue
+		}
+
+		parameters[name] = value
+	}
+
+	if len(violations) > 0 {
+		return nil, exceptions.NewValidation("invalid variables", violations...)
+	}
+
+	value, err := compiled.Evaluate(parameters)
+	if err != nil {
+		return nil, exceptions.NewValidation("expression failed: " + err.Error()).WithCode("expression_failed")
+	}
+
+	number, ok := value.(float64)
+	if !ok || math.IsNaN(number) || math.IsInf(number, 0) {
+		return nil, exceptions.NewValidation(fmt.Sprintf("expression must compute a number, got %v", value)).WithCode("expression_failed")
+	}
+
+	number = round(number, scale)
+
+	return &Result{Number: number, Value: strconv.FormatFloat(number, 'f', scale, 64)}, nil
+}
+
+// read converts the payload value of the variable name to what govaluate
+// expects for its type.
+func (e *Engine) read(name string, value interface{}) (interface{}, error) {
+	switch e.variables[name].Type {
+	case Number:
+		switch number := value.(type) {
+		case float64, float32, int, int32, int64:
+			return number, nil
+		case string:
+			if parsed, err := strconv.ParseFloat(number, 64); err == nil {
+				return parsed, nil
+			}
+		}
+
+		return nil, errors.New("must be a number")
+	case Bool:
+		if flag, ok := value.(bool); ok {
+			return flag, nil
+		}
+
+		return nil, errors.New("must be true or false")
+	case Date:
+		if text, ok := value.(string); ok {
+			for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+				if date, err := time.Parse(layout, text); err == nil {
+					return float64(date.Unix()), nil
+				}
+			}
+		}
+
+		return nil, errors.New("must be a date like 2006-01-02 or an RFC 3339 timestamp")
+	default:
+		if text, ok := value.(string); ok {
+			return text, nil
+		}
+
+		return nil, errors.New("must be a string")
+	}
+}
+
+func invalid(rule string, message string) error {
+	return exceptions.NewValidation("invalid expression",
+		exceptions.FieldError{Field: "Expression", Rule: rule, Message: message})
+}
+
+// numbers adapts fn to a govaluate function taking between minArgs and
+// maxArgs numbers, maxArgs -1 allowing any number.
+func numbers(name string, minArgs int, maxArgs int, fn func(args []float64) float64) govaluate.ExpressionFunction {
+	return func(arguments ...interface{}) (interface{}, error) {
+		if len(arguments) < minArgs || (maxArgs >= 0 && len(arguments) > maxArgs) {
+			return nil, fmt.Errorf("%s: wrong number of arguments", name)
+		}
+
+		args := make([]float64, 0, len(arguments))
+
+		for _, argument := range arguments {
+			number, ok := argument.(float64)
+			if !ok {
+				return nil, fmt.Errorf("%s: %v is not a number", name, argument)
+			}
+
+			args = append(args, number)
+		}
+
+		return fn(args), nil
+	}
+}
+
+func fold(args []float64, fn func(a, b float64) float64) float64 {
+	result := args[0]
+	for _, arg := range args[1:] {
+		result = fn(result, arg)
+	}
+
+	return result
+}
+
+// round rounds half away from zero to scale decimal places.
+func round(value float64, scale int) float64 {
+	factor := math.Pow(10, float64(scale))
+
+	return math.Round(value*factor) / factor
+}
@@ -0,0 +1,80 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"restapi/exceptions"
+)
+
+func newTestEngine() *Engine {
+	engine := NewEngine(Variables...)
+	engine.now = func() time.Time { return time.Date(2024, 3, 11, 12, 0, 0, 0, time.UTC) }
+
+	return engine
+}
+
+func TestEngine_Evaluate(t *testing.T) {
+	engine := newTestEngine()
+
+	cases := []struct {
+		expression string
+		scale      int
+		want       string
+	}{
+		{"amount * 0.015", 2, "1.50"},
+		{"clamp(amount / 3, 10, 20)", 0, "20"},
+		{"min(amount, 40, action_count * 10) + max(1, 2)", 1, "32.0"},
+		{"user_tier == 'gold' ? round(amount / 7, 3) : 0", 3, "14.286"},
+		{"days_since(signup_date) > 30 && user_verified ? 5 : 1", 0, "5"},
+	}
+
+	payload := map[string]interface{}{
+		"amount":        float64(100),
+		"action_count":  3,
+		"user_tier":     "gold",
+		"user_verified": true,
+		"signup_date":   "2024-01-01",
+	}
+
+	for _, tc := range cases {
+		result, err := engine.Evaluate(tc.expression, tc.scale, payload)
+		if err != nil {
+			t.Errorf("%s: %s", tc.expression, err)
+
+			continue
+		}
+
+		if result.Value != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.expression, result.Value, tc.want)
+		}
+	}
+}
+
+func TestEngine_Compile(t *testing.T) {
+	engine := newTestEngine()
+
+	for _, expression := range []string{"", "amount *", "balance * 2", "unknown_fn(amount)"} {
+		if err := engine.Compile(expression); !exceptions.Is(err, exceptions.Validation) {
+			t.Errorf("%q: expected a validation error, got %v", expression, err)
+		}
+	}
+
+	if err := engine.Compile("amount * 2"); err != nil {
+		t.Fatalf("Compile: %s", err)
+	}
+
+	if _, ok := engine.compiled["amount * 2"]; !ok {
+		t.Error("compiled expression not cached")
+	}
+
+	if _, err := engine.Evaluate("amount * user_level", 0, map[string]interface{}{"amount": "abc"}); err == nil {
+		t.Error("expected bad and missing variables to be reported")
+	} else if fields := exceptions.From(err).Fields; len(fields) != 2 {
+		t.Errorf("got %v", fields)
+	}
+
+	if _, err := engine.Evaluate("amount > 1", 0, map[string]interface{}{"amount": 2}); err == nil {
+		t.Error("expected a boolean result to be rejected")
+	}
+}
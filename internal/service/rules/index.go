@@ -0,0 +1,42 @@
+package rules
+
+import (
+	"restapi/internal/dao/mysql"
+
+	"restapi/db"
+)
+
+// Variables are what currency expressions are evaluated with, callers
+// fill them from the user and the action being rewarded.
+var Variables = []Variable{
+	{Name: "amount", Type: Number, Description: "amount of the transaction"},
+	{Name: "user_level", Type: Number, Description: "level of the user"},
+	{Name: "user_tier", Type: String, Description: "tier of the user, e.g. gold"},
+	{Name: "user_verified", Type: Bool, Description: "whether the user is verified"},
+	{Name: "action_count", Type: Number, Description: "times the user did the action in the counting interval"},
+	{Name: "signup_date", Type: Date, Description: "when the user signed up"},
+	{Name: "event_date", Type: Date, Description: "when the action happened"},
+}
+
+type Service struct {
+	ruleDao *mysql.RuleDao
+	engine  *Engine
+}
+
+func NewRulesService(replicas *db.ReplicaSet,
+	masterDB *db.DB,
+) *Service {
+	if replicas == nil || masterDB == nil {
+		panic("db cannot be null")
+	}
+
+	return &Service{
+		ruleDao: mysql.NewRuleDao(replicas, masterDB),
+		engine:  NewEngine(Variables...),
+	}
+}
+
+// Variables lists the variables expressions may use.
+func (rs *Service) Variables() []Variable {
+	return rs.engine.Variables()
+}
@@ -0,0 +1,126 @@
+package rules
+
+import (
+	"context"
+	"strings"
+
+	"restapi/exceptions"
+	models "restapi/internal/model"
+)
+
+// Evaluation is how one rule evaluated for a payload, Error says why it
+// could not.
+type Evaluation struct {
+	RuleID     int     `json:"RuleId,omitempty"`
+	Type       string  `json:"Type,omitempty"`
+	Expression string  `json:"Expression"`
+	Scale      int     `json:"Scale"`
+	Result     *Result `json:"Result,omitempty"`
+	Error      string  `json:"Error,omitempty"`
+}
+
+// DryRun evaluates Expression, or every rule of the action when it is
+// empty, for the variables of a sample payload. Nothing is saved.
+type DryRun struct {
+	Expression string
+	Scale      int
+	Variables  map[string]interface{}
+}
+
+// List returns the rules of the action, compiling them for Evaluate.
+func (rs *Service) List(ctx context.Context, actionID int) ([]models.ActionCurrencyRule, error) {
+	rules, err := rs.ruleDao.FetchActionCurrencyRules(ctx, actionID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range rules {
+		// a rule saved before the engine knew it is reported when it runs
+		_ = rs.engine.Compile(rule.Expression)
+	}
+
+	return rules, nil
+}
+
+// Add validates and compiles the expression of rule before saving it.
+func (rs *Service) Add(ctx context.Context, rule models.ActionCurrencyRule) (*models.ActionCurrencyRule, error) {
+	if err := rs.engine.Compile(rule.Expression); err != nil {
+		return nil, err
+	}
+
+	if rule.Info == "" {
+		rule.Info = "{}"
+	}
+
+	id, err := rs.ruleDao.AddActionCurrencyRule(ctx, nil, &rule)
+	if exceptions.Is(err, exceptions.Validation) {
+		// the foreign key to actions is the only reference a rule has
+		return nil, exceptions.NewNotFound("action not found").WithCode("action_not_found").Wrap(err)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	rule.ID = int(id)
+
+	return &rule, nil
+}
+
+// Evaluate runs every rule of the action with variables.
+func (rs *Service) Evaluate(ctx context.Context, actionID int, variables map[string]interface{}) ([]Evaluation, error) {
+	rules, err := rs.List(ctx, actionID)
+	if err != nil {
+		return nil, err
+	}
+
+	evaluations := make([]Evaluation, 0, len(rules))
+
+	for _, rule := range rules {
+		evaluation := Evaluation{
+			RuleID:     rule.ID,
+			Type:       rule.Type,
+			Expression: rule.Expression,
+			Scale:      rule.Scale,
+		}
+
+		evaluation.Result, err = rs.engine.Evaluate(rule.Expression, rule.Scale, variables)
+		if err != nil {
+			evaluation.Error = describe(err)
+		}
+
+		evaluations = append(evaluations, evaluation)
+	}
+
+	return evaluations, nil
+}
+
+// DryRun shows how input would evaluate for the action.
+func (rs *Service) DryRun(ctx context.Context, actionID int, input DryRun) ([]Evaluation, error) {
+	if input.Expression == "" {
+		return rs.Evaluate(ctx, actionID, input.Variables)
+	}
+
+	result, err := rs.engine.Evaluate(input.Expression, input.Scale, input.Variables)
+	if err != nil {
+		return nil, err
+	}
+
+	return []Evaluation{{Expression: input.Expression, Scale: input.Scale, Result: result}}, nil
+}
+
+// describe is the message of err with the fields it rejects.
+func describe(err error) string {
+	e := exceptions.From(err)
+
+	problems := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		problems = append(problems, strings.TrimSpace(field.Field+" "+field.Message))
+	}
+
+	if len(problems) == 0 {
+		return e.Message
+	}
+
+	return e.Message + ": " + strings.Join(problems, ", ")
+}
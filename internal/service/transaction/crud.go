@@ -0,0 +1,146 @@
+package transaction
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"restapi/exceptions"
+	models "restapi/internal/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Patch carries the fields of a partial update, nil fields are left untouched.
+type Patch struct {
+	Code         *string
+	CompanyId    *int32
+	JobprofileId *int32
+}
+
+// Create inserts input and queues a transaction.created event with it. A
+// transaction rewarding a user for an action is refused with an
+// exceptions.Conflict when the user is blacklisted and an
+// exceptions.RateLimited when the user reached the limit of the action.
+func (as *Service) Create(ctx context.Context, input models.Transaction) (*models.Transaction, error) {
+	reward, err := as.checkReward(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	err = as.transactionDao.Transaction(ctx, nil, func(ctx context.Context, tx *sqlx.Tx) error {
+		if reward != nil {
+			if err := as.recheck(ctx, tx, reward); err != nil {
+				return err
+			}
+		}
+
+		id, err := as.transactionDao.Create(ctx, tx, &input)
+		if err != nil {
+			return err
+		}
+
+		input.TxnId = int32(id)
+
+		return as.addEvent(ctx, tx, EventTransactionCreated, input)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if reward != nil {
+		as.forget(ctx, reward)
+	}
+
+	return &input, nil
+}
+
+// Get reads from a replica, fromMaster trades load on the master for
+// seeing writes that have not replicated yet.
+func (as *Service) Get(ctx context.Context, txnID int64, fromMaster bool) (*models.Transaction, error) {
+	dao := as.transactionDao
+	if fromMaster {
+		dao = dao.FromMaster()
+	}
+
+	result, err := dao.GetTransactionByID(ctx, nil, txnID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound(txnID)
+	}
+
+	return result, err
+}
+
+// Update replaces the editable fields of the transaction, the action and
+// user it rewards cannot change.
+func (as *Service) Update(ctx context.Context, txnID int64, input models.Transaction) (*models.Transaction, error) {
+	return as.modify(ctx, txnID, func(current *models.Transaction) {
+		current.Code = input.Code
+		current.CompanyId = input.CompanyId
+		current.JobprofileId = input.JobprofileId
+	})
+}
+
+func (as *Service) Patch(ctx context.Context, txnID int64, patch Patch) (*models.Transaction, error) {
+	return as.modify(ctx, txnID, func(current *models.Transaction) {
+		if patch.Code != nil {
+			current.Code = *patch.Code
+		}
+
+		if patch.CompanyId != nil {
+			current.CompanyId = *patch.CompanyId
+		}
+
+		if patch.JobprofileId != nil {
+			current.JobprofileId = *patch.JobprofileId
+		}
+	})
+}
+
+func (as *Service) Delete(ctx context.Context, txnID int64) error {
+	affected, err := as.transactionDao.Delete(ctx, nil, txnID)
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return notFound(txnID)
+	}
+
+	return nil
+}
+
+// modify locks the row, applies the mutation and writes it back in a
+// single transaction.
+func (as *Service) modify(ctx context.Context, txnID int64, mutate func(current *models.Transaction)) (*models.Transaction, error) {
+	var current *models.Transaction
+
+	err := as.transactionDao.Transaction(ctx, nil, func(ctx context.Context, tx *sqlx.Tx) error {
+		var err error
+
+		current, err = as.transactionDao.GetTransactionByID(ctx, tx, txnID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return notFound(txnID)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		mutate(current)
+
+		_, err = as.transactionDao.Update(ctx, tx, current)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return current, nil
+}
+
+func notFound(txnID int64) error {
+	return exceptions.NewNotFound(fmt.Sprintf("transaction %d not found", txnID)).WithCode("transaction_not_found")
+}
@@ -0,0 +1,96 @@
+package transaction
+
+import (
+	"context"
+	"strconv"
+
+	models "restapi/internal/model"
+	"restapi/kafka"
+
+	"github.com/IBM/sarama"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	EventTransactionCreated = "transaction.created"
+
+	eventSource = "restapi/transactions"
+)
+
+// transactionEvent is the data of the events of a transaction.
+type transactionEvent struct {
+	eventType string
+
+	TxnId        int32  `json:"TxnId"`
+	Code         string `json:"Code"`
+	CompanyId    int32  `json:"CompanyId"`
+	JobprofileId int32  `json:"JobprofileId"`
+	ActionId     *int64 `json:"ActionId,omitempty"`
+	UserId       string `json:"UserId,omitempty"`
+}
+
+func (e transactionEvent) EventType() string { return e.eventType }
+
+func (e transactionEvent) EventVersion() int { return 1 }
+
+// PartitionKey keeps the events of a company in order.
+func (e transactionEvent) PartitionKey() string { return strconv.Itoa(int(e.CompanyId)) }
+
+func init() {
+	kafka.RegisterSchema(kafka.Schema{
+		Type:      EventTransactionCreated,
+		Version:   1,
+		Format:    kafka.FormatJSON,
+		Prototype: transactionEvent{},
+	})
+}
+
+// addEvent queues an event about transaction in the outbox, within tx so
+// that it is published if and only if tx commits.
+func (as *Service) addEvent(ctx context.Context, tx *sqlx.Tx, eventType string, transaction models.Transaction) error {
+	message, _, err := as.events.Encode(ctx, as.eventsTopic, transactionEvent{
+		eventType:    eventType,
+		TxnId:        transaction.TxnId,
+		Code:         transaction.Code,
+		CompanyId:    transaction.CompanyId,
+		JobprofileId: transaction.JobprofileId,
+		ActionId:     transaction.ActionId.Ptr(),
+		UserId:       transaction.UserId.ValueOrZero(),
+	})
+	if err != nil {
+		return err
+	}
+
+	row, err := outboxMessage(message)
+	if err != nil {
+		return err
+	}
+
+	_, err = as.outboxDao.Add(ctx, tx, row)
+
+	return err
+}
+
+// outboxMessage is the outbox row that the relay turns back into message.
+func outboxMessage(message *sarama.ProducerMessage) (*models.OutboxMessage, error) {
+	row := &models.OutboxMessage{Topic: message.Topic}
+
+	var err error
+
+	if message.Key != nil {
+		if row.Key, err = message.Key.Encode(); err != nil {
+			return nil, err
+		}
+	}
+
+	if row.Payload, err = message.Value.Encode(); err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string]string, len(message.Headers))
+	for _, header := range message.Headers {
+		headers[string(header.Key)] = string(header.Value)
+	}
+
+	return row, row.SetHeaders(headers)
+}
@@ -1,23 +1,56 @@
 package transaction
 
 import (
+	"time"
+
+	"restapi/cache"
 	"restapi/internal/dao/mysql"
+	"restapi/kafka"
 
 	"restapi/db"
 )
 
+// Options are the collaborators and settings of the service besides the
+// database.
+type Options struct {
+	// Counts caches CountUserActionsInInterval for CountTTL
+	Counts   cache.Cache
+	CountTTL time.Duration
+	// EventsTopic receives the events of transactions through the outbox
+	EventsTopic string
+}
+
 type Service struct {
 	transactionDao *mysql.TransactionDao
+	outboxDao      *mysql.OutboxDao
+	counts         cache.Cache
+	countTTL       time.Duration
+	eventsTopic    string
+	events         *kafka.Encoder
 }
 
-func NewTransactionService(dB *db.DB,
+func NewTransactionService(replicas *db.ReplicaSet,
 	masterDB *db.DB,
+	opts Options,
 ) *Service {
-	if dB == nil {
+	if replicas == nil || masterDB == nil {
 		panic("db cannot be null")
 	}
 
+	if opts.Counts == nil {
+		panic("cache cannot be null")
+	}
+
+	if opts.EventsTopic == "" {
+		panic("events topic cannot be empty")
+	}
+
 	return &Service{
-		transactionDao: mysql.NewTransactionDao(dB),
+		transactionDao: mysql.NewTransactionDao(replicas, masterDB),
+		outboxDao:      mysql.NewOutboxDao(replicas, masterDB),
+		counts:         opts.Counts,
+		countTTL:       opts.CountTTL,
+		eventsTopic:    opts.EventsTopic,
+		events:         kafka.NewEncoder(eventSource),
 	}
 }
@@ -1,7 +1,58 @@
 package transaction
 
-import models "restapi/internal/model"
+import (
+	"context"
 
-func (as *Service) Info() ([]models.Transaction, error) {
-	return as.transactionDao.FetchAllActiveActions()
+	"restapi/helpers"
+	models "restapi/internal/model"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+func (as *Service) Info(ctx context.Context, filter models.TransactionFilter) ([]models.Transaction, helpers.Pagination, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultPageSize
+	}
+
+	filter.Limit = helpers.Min(filter.Limit, MaxPageSize)
+
+	pagination := helpers.Pagination{Limit: filter.Limit}
+
+	result, err := as.transactionDao.FetchAllActiveActions(ctx, filter)
+	if err != nil {
+		return nil, pagination, err
+	}
+
+	if len(result) <= filter.Limit {
+		return result, pagination, nil
+	}
+
+	result = result[:filter.Limit]
+	last := result[len(result)-1]
+
+	pagination.HasMore = true
+	pagination.NextCursor, err = helpers.EncodeCursor(models.TransactionCursor{
+		SortBy:     filter.SortBy,
+		Descending: filter.Descending,
+		SortValue:  sortValue(last, filter.SortBy),
+		TxnId:      last.TxnId,
+	})
+
+	return result, pagination, err
+}
+
+func sortValue(row models.Transaction, sortBy string) interface{} {
+	switch sortBy {
+	case "code":
+		return row.Code
+	case "companyId":
+		return row.CompanyId
+	case "jobProfileId":
+		return row.JobprofileId
+	default:
+		return row.TxnId
+	}
 }
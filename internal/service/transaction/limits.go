@@ -0,0 +1,121 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+
+	"restapi/exceptions"
+	models "restapi/internal/model"
+	"restapi/logger"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const actionCountSet = "action_counts"
+
+// rewardLimit is what a transaction rewarding a user for an action is
+// checked against.
+type rewardLimit struct {
+	actionID int
+	userID   string
+	limit    models.ActionFrequencyLimit
+}
+
+func (r rewardLimit) cacheKey() string {
+	return fmt.Sprintf("%d:%s:%d", r.actionID, r.userID, r.limit.WindowDays.Int64)
+}
+
+// checkReward rejects blacklisted users and users that already reached
+// the limit of the action, going by the cached count. It returns nil for
+// transactions that reward no one or actions without a limit.
+func (as *Service) checkReward(ctx context.Context, input models.Transaction) (*rewardLimit, error) {
+	if !input.ActionId.Valid || !input.UserId.Valid {
+		return nil, nil
+	}
+
+	blacklisted, err := as.transactionDao.IsBlacklisted(ctx, input.UserId.String)
+	if err != nil {
+		return nil, err
+	}
+
+	if blacklisted {
+		return nil, exceptions.NewConflict("user " + input.UserId.String + " is blacklisted").WithCode("user_blacklisted")
+	}
+
+	limit, err := as.transactionDao.GetActionFrequencyLimit(ctx, int(input.ActionId.Int64))
+	if exceptions.Is(err, exceptions.NotFound) {
+		return nil, exceptions.NewValidation("action not found",
+			exceptions.FieldError{Field: "ActionId", Rule: "exists", Message: "action not found"}).Wrap(err)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !limit.Enabled() {
+		return nil, nil
+	}
+
+	reward := &rewardLimit{actionID: int(input.ActionId.Int64), userID: input.UserId.String, limit: *limit}
+
+	count, err := as.cachedCount(ctx, reward)
+	if err != nil {
+		return nil, err
+	}
+
+	return reward, reward.check(count)
+}
+
+// check returns an exceptions.RateLimited once count reached the limit.
+func (r rewardLimit) check(count int) error {
+	if int64(count) < r.limit.Limit.Int64 {
+		return nil
+	}
+
+	return exceptions.NewRateLimited(fmt.Sprintf("user %s already earned from action %d %d times in %d days",
+		r.userID, r.actionID, count, r.limit.WindowDays.Int64)).WithCode("action_limit_reached")
+}
+
+// cachedCount counts the recent transactions of the reward on a replica,
+// caching the count for countTTL, which 0 turns off. Cache failures fall
+// back to the database.
+func (as *Service) cachedCount(ctx context.Context, r *rewardLimit) (int, error) {
+	var cached int
+
+	if found, err := as.counts.GetJson(actionCountSet, r.cacheKey(), &cached); err == nil && found != nil {
+		return cached, nil
+	}
+
+	count, err := as.transactionDao.CountUserActionsInInterval(ctx, nil, r.actionID, r.userID, int(r.limit.WindowDays.Int64))
+	if err != nil {
+		return 0, err
+	}
+
+	if as.countTTL <= 0 {
+		return count, nil
+	}
+
+	if err := as.counts.SetJson(actionCountSet, r.cacheKey(), count, int(as.countTTL.Seconds())); err != nil {
+		logger.Error(ctx, "unable to cache action count", logger.Z{"error": err.Error(), "key": r.cacheKey()})
+	}
+
+	return count, nil
+}
+
+// recheck counts again on the master inside tx, locking the counted rows
+// so that concurrent rewards for the same user and action are serialised.
+func (as *Service) recheck(ctx context.Context, tx *sqlx.Tx, r *rewardLimit) error {
+	count, err := as.transactionDao.CountUserActionsInInterval(ctx, tx, r.actionID, r.userID, int(r.limit.WindowDays.Int64))
+	if err != nil {
+		return err
+	}
+
+	return r.check(count)
+}
+
+// forget drops the cached count of the reward after a new transaction.
+func (as *Service) forget(ctx context.Context, r *rewardLimit) {
+	if err := as.counts.Delete(actionCountSet, r.cacheKey()); err != nil {
+		logger.Error(ctx, "unable to invalidate action count", logger.Z{"error": err.Error(), "key": r.cacheKey()})
+	}
+}
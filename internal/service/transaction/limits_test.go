@@ -0,0 +1,36 @@
+package transaction
+
+import (
+	"testing"
+
+	"restapi/exceptions"
+	models "restapi/internal/model"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestRewardLimit_Check(t *testing.T) {
+	reward := rewardLimit{
+		actionID: 7,
+		userID:   "u1",
+		limit:    models.ActionFrequencyLimit{ActionID: 7, Limit: null.IntFrom(3), WindowDays: null.IntFrom(30)},
+	}
+
+	if err := reward.check(2); err != nil {
+		t.Errorf("below the limit: %s", err)
+	}
+
+	for _, count := range []int{3, 4} {
+		if err := reward.check(count); !exceptions.Is(err, exceptions.RateLimited) {
+			t.Errorf("%d: expected rate limited, got %v", count, err)
+		}
+	}
+
+	if key := reward.cacheKey(); key != "7:u1:30" {
+		t.Errorf("got key %s", key)
+	}
+
+	if (models.ActionFrequencyLimit{Limit: null.IntFrom(3)}).Enabled() {
+		t.Error("a limit without a window must be disabled")
+	}
+}
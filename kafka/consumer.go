@@ -3,24 +3,28 @@ package kafka
 import (
 	"context"
 	"crypto/sha1"
+	"encoding/hex"
+	"errors"
 	"log"
-	"os"
 	"strings"
 	"time"
 
+	"restapi/config"
 	"restapi/logger"
-	helpers "restapi/util"
 
 	"github.com/IBM/sarama"
 )
 
 type ConsumerGroup struct {
 	Client sarama.ConsumerGroup
+	// Retry handles failed messages, without it they are logged and skipped
+	Retry *RetryPolicy
 }
 
 type Consumer struct {
 	Ready     chan bool
 	Processor Processor
+	Retry     *RetryPolicy
 }
 type Params struct {
 	SessionTimeout    time.Duration
@@ -37,17 +41,17 @@ func NewConsumer(ready chan bool, processor Processor) Consumer {
 }
 
 func NewConsumerConfig(env string, prefix string, param Params) *sarama.Config {
-	// load env if not already loaded
-	if len(os.Getenv(prefix+"_KAFKA_VERSION")) == 0 {
-		helpers.LoadEnv(env)
-	}
+	return NewSaramaConsumerConfig(loadConfig(env).KafkaCluster(prefix), param)
+}
 
+// NewSaramaConsumerConfig builds the consumer settings for the cluster of cfg.
+func NewSaramaConsumerConfig(cfg config.Kafka, param Params) *sarama.Config {
 	/**
 	 * Construct a new Sarama configuration.
 	 * The Kafka cluster version has to be defined before the consumer is initialized.
 	 */
 
-	version, err := sarama.ParseKafkaVersion(os.Getenv(prefix + "_KAFKA_VERSION"))
+	version, err := sarama.ParseKafkaVersion(cfg.Version)
 	if err != nil {
 		log.Panicf("Error parsing Kafka version: %v", err)
 	}
@@ -93,22 +97,19 @@ func NewConsumerConfig(env string, prefix string, param Params) *sarama.Config {
 }
 
 func NewConsumerGroup(env string, prefix string, param Params) *ConsumerGroup {
+	return NewConsumerGroupFromConfig(loadConfig(env).KafkaCluster(prefix), param)
+}
 
-	instance := &ConsumerGroup{}
+// NewConsumerGroupFromConfig joins cfg.Group on the cluster of cfg.
+func NewConsumerGroupFromConfig(cfg config.Kafka, param Params) *ConsumerGroup {
 
-	// load env if not already loaded
-	if len(os.Getenv(prefix+"_KAFKA_BROKER")) == 0 {
-		helpers.LoadEnv(env)
-	}
-
-	config := NewConsumerConfig(env, prefix, param)
+	instance := &ConsumerGroup{}
 
-	brokers := os.Getenv(prefix + "_KAFKA_BROKER")
-	group := os.Getenv(prefix + "_KAFKA_GROUP")
+	config := NewSaramaConsumerConfig(cfg, param)
 
-	logger.Info(nil, "Setting up new kafka consumergroup", logger.Z{"group": group, "brokers": brokers})
+	logger.Info(nil, "Setting up new kafka consumergroup", logger.Z{"group": cfg.Group, "brokers": strings.Join(cfg.Brokers, ",")})
 
-	client, err := sarama.NewConsumerGroup(strings.Split(brokers, ","), group, config)
+	client, err := sarama.NewConsumerGroup(cfg.Brokers, cfg.Group, config)
 	if err != nil {
 		log.Fatalf("Could not set up kafka consumer group ERR: %v", err)
 	}
@@ -117,6 +118,41 @@ func NewConsumerGroup(env string, prefix string, param Params) *ConsumerGroup {
 	return instance
 }
 
+// loadConfig reads the settings of the env file for the older constructors,
+// which stop the process on bad settings.
+func loadConfig(env string) *config.Config {
+	cfg, err := config.Load(env)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	return cfg
+}
+
+// Consume joins the group for topics, and their retry topics, and hands
+// every message to processor until ctx is cancelled. Sessions end on every
+// rebalance, so the group is re-joined in a loop.
+func (group *ConsumerGroup) Consume(ctx context.Context, topics []string, processor Processor) error {
+	topics = group.Retry.Topics(topics)
+
+	for {
+		consumer := NewConsumer(make(chan bool), processor)
+		consumer.Retry = group.Retry
+
+		if err := group.Client.Consume(ctx, topics, &consumer); err != nil {
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				return nil
+			}
+
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
 // Setup is run at the beginning of a new session, before ConsumeClaim
 func (consumer *Consumer) Setup(sarama.ConsumerGroupSession) error {
 	// Mark the consumer as ready
@@ -142,8 +178,7 @@ func (consumer *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, clai
 	// https://github.com/Shopify/sarama/blob/master/consumer_group.go#L27-L29
 	for message := range claim.Messages() {
 
-		tid := hash(string(message.Value))
-		ctx := context.WithValue(context.Background(), logger.TransactionIDKey, tid)
+		ctx := logger.WithTransactionID(context.Background(), transactionID(message))
 
 		logData := logData{
 			Message:          string(message.Value),
@@ -153,8 +188,30 @@ func (consumer *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, clai
 			MessageTimestamp: &message.Timestamp,
 		}
 
+		messagesConsumed.WithLabelValues(message.Topic).Inc()
+		recordLag(message.Topic, message.Partition, claim.HighWaterMarkOffset(), message.Offset)
+
+		if consumer.Retry != nil {
+			// unmarked messages are consumed again by the next session
+			sessionCtx := logger.WithTransactionID(session.Context(), transactionID(message))
+
+			if err := consumer.Retry.handle(sessionCtx, message, consumer.Processor); err != nil {
+				if session.Context().Err() != nil {
+					return nil
+				}
+
+				return err
+			}
+
+			session.MarkMessage(message, "")
+
+			continue
+		}
+
 		err := consumer.Processor.Process(ctx, string(message.Value), message.Timestamp, message.Topic)
 		if err != nil {
+			consumeErrors.WithLabelValues(message.Topic).Inc()
+
 			logData.Error = err.Error()
 			logger.Error(ctx, "Could not Process message", logger.Z{"log_data": logData})
 		}
@@ -165,6 +222,18 @@ func (consumer *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, clai
 	return nil
 }
 
+// transactionID restores the correlation id set by the producer, messages
+// from producers that do not send one get an id derived from their payload.
+func transactionID(message *sarama.ConsumerMessage) string {
+	for _, header := range message.Headers {
+		if header != nil && string(header.Key) == RequestIDHeader && len(header.Value) > 0 {
+			return string(header.Value)
+		}
+	}
+
+	return hex.EncodeToString(hash(string(message.Value)))
+}
+
 func hash(s string) []byte {
 	h := sha1.New()
 	h.Write([]byte(s))
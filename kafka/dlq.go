@@ -0,0 +1,177 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"restapi/logger"
+
+	"github.com/IBM/sarama"
+)
+
+// dlqIdleTimeout is how long a partition of a dead-letter queue has to be
+// quiet before ReplayDLQ takes it for drained.
+const dlqIdleTimeout = 5 * time.Second
+
+// ReplayDLQ republishes the messages of the dead-letter queues topics to
+// the topics they failed on, without the headers of RetryPolicy, so that
+// they start over. It stops once it caught up with every partition or
+// replayed limit messages, 0 meaning no limit. Offsets are committed for
+// the group, a later run continues where this one stopped. It returns how
+// many messages were replayed.
+func (group *ConsumerGroup) ReplayDLQ(ctx context.Context, topics []string, producer *Producer, limit int) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	replayer := &dlqReplayer{producer: producer, limit: int64(limit), finish: cancel}
+
+	for ctx.Err() == nil {
+		if err := group.Client.Consume(ctx, topics, replayer); err != nil {
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				break
+			}
+
+			return int(replayer.replayed.Load()), err
+		}
+	}
+
+	replayer.mu.Lock()
+	defer replayer.mu.Unlock()
+
+	return int(replayer.replayed.Load()), replayer.err
+}
+
+type dlqReplayer struct {
+	producer *Producer
+	limit    int64
+	finish   context.CancelFunc
+	replayed atomic.Int64
+
+	mu      sync.Mutex
+	pending int
+	err     error
+}
+
+func (r *dlqReplayer) Setup(session sarama.ConsumerGroupSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pending = 0
+	for _, partitions := range session.Claims() {
+		r.pending += len(partitions)
+	}
+
+	if r.pending == 0 {
+		r.finish()
+	}
+
+	return nil
+}
+
+func (r *dlqReplayer) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// drained ends the replay once every claimed partition is drained.
+func (r *dlqReplayer) drained() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pending--; r.pending <= 0 {
+		r.finish()
+	}
+}
+
+// abort ends the replay with err, messages are not skipped.
+func (r *dlqReplayer) abort(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err == nil {
+		r.err = err
+	}
+
+	r.finish()
+}
+
+func (r *dlqReplayer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	idle := time.NewTimer(dlqIdleTimeout)
+	defer idle.Stop()
+
+	for {
+		select {
+		case <-session.Context().Done():
+			return nil
+		case <-idle.C:
+			r.drained()
+
+			return nil
+		case message, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+
+			if r.limit > 0 && r.replayed.Load() >= r.limit {
+				r.finish()
+
+				return nil
+			}
+
+			if err := r.replay(message); err != nil {
+				r.abort(err)
+
+				return err
+			}
+
+			session.MarkMessage(message, "")
+
+			if r.replayed.Add(1) == r.limit {
+				r.finish()
+
+				return nil
+			}
+
+			if message.Offset+1 >= claim.HighWaterMarkOffset() {
+				r.drained()
+
+				return nil
+			}
+
+			idle.Reset(dlqIdleTimeout)
+		}
+	}
+}
+
+func (r *dlqReplayer) replay(message *sarama.ConsumerMessage) error {
+	state := stateOf(message)
+	if state.topic == message.Topic {
+		return fmt.Errorf("kafka: offset %d of %s/%d has no %s header", message.Offset, message.Topic, message.Partition, HeaderOriginalTopic)
+	}
+
+	replayed := &sarama.ProducerMessage{
+		Topic:   state.topic,
+		Value:   sarama.ByteEncoder(message.Value),
+		Headers: withoutRetryHeaders(message.Headers),
+	}
+
+	if message.Key != nil {
+		replayed.Key = sarama.ByteEncoder(message.Key)
+	}
+
+	if _, _, err := r.producer.SendMessage(replayed); err != nil {
+		return fmt.Errorf("kafka: replaying offset %d of %s/%d: %w", message.Offset, message.Topic, message.Partition, err)
+	}
+
+	logger.Info(context.Background(), "replayed dead letter", logger.Z{
+		"topic":    state.topic,
+		"dlq":      message.Topic,
+		"offset":   message.Offset,
+		"attempts": state.attempts,
+	})
+
+	return nil
+}
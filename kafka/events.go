@@ -0,0 +1,160 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"restapi/logger"
+
+	"github.com/IBM/sarama"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+)
+
+// Headers of event messages, they repeat the envelope so that consumers can
+// route and drop messages without decoding them.
+const (
+	HeaderEventID     = "event-id"
+	HeaderEventType   = "event-type"
+	HeaderVersion     = "event-version"
+	HeaderOccurredAt  = "occurred-at"
+	HeaderSource      = "source"
+	HeaderContentType = "content-type"
+)
+
+// Event is a domain event. Events with the same PartitionKey land on the
+// same partition and so keep their order.
+type Event interface {
+	EventType() string
+	EventVersion() int
+	PartitionKey() string
+}
+
+// Envelope is what describes every event. CorrelationID is the id of the
+// request that caused the event.
+type Envelope struct {
+	ID            string    `json:"id"`
+	Type          string    `json:"type"`
+	Version       int       `json:"version"`
+	OccurredAt    time.Time `json:"occurredAt"`
+	Source        string    `json:"source"`
+	CorrelationID string    `json:"correlationId,omitempty"`
+	Data          Event     `json:"data"`
+}
+
+// NewEnvelope wraps event, taking the correlation id from ctx.
+func NewEnvelope(ctx context.Context, source string, event Event) Envelope {
+	return Envelope{
+		ID:            uuid.NewString(),
+		Type:          event.EventType(),
+		Version:       event.EventVersion(),
+		OccurredAt:    time.Now().UTC(),
+		Source:        source,
+		CorrelationID: logger.TransactionID(ctx),
+		Data:          event,
+	}
+}
+
+// Headers are the standard headers of the message carrying envelope.
+func (e Envelope) Headers(contentType string) []sarama.RecordHeader {
+	headers := []sarama.RecordHeader{
+		{Key: []byte(HeaderEventID), Value: []byte(e.ID)},
+		{Key: []byte(HeaderEventType), Value: []byte(e.Type)},
+		{Key: []byte(HeaderVersion), Value: []byte(strconv.Itoa(e.Version))},
+		{Key: []byte(HeaderOccurredAt), Value: []byte(e.OccurredAt.Format(time.RFC3339Nano))},
+		{Key: []byte(HeaderSource), Value: []byte(e.Source)},
+		{Key: []byte(HeaderContentType), Value: []byte(contentType)},
+	}
+
+	if e.CorrelationID != "" {
+		headers = append(headers, sarama.RecordHeader{Key: []byte(RequestIDHeader), Value: []byte(e.CorrelationID)})
+	}
+
+	return headers
+}
+
+// Format names a serialization, schemas are registered for one.
+type Format string
+
+const (
+	FormatJSON     Format = "json"
+	FormatProtobuf Format = "protobuf"
+)
+
+// Serializer turns an envelope into a message value.
+type Serializer interface {
+	Format() Format
+	ContentType() string
+	Serialize(envelope Envelope) ([]byte, error)
+}
+
+// JSONSerializer writes the whole envelope, the event under "data".
+type JSONSerializer struct{}
+
+func (JSONSerializer) Format() Format { return FormatJSON }
+
+func (JSONSerializer) ContentType() string { return "application/json" }
+
+func (JSONSerializer) Serialize(envelope Envelope) ([]byte, error) {
+	return json.Marshal(envelope)
+}
+
+// ProtobufSerializer writes the event only, which must be a proto.Message,
+// the envelope travels in the headers.
+type ProtobufSerializer struct{}
+
+func (ProtobufSerializer) Format() Format { return FormatProtobuf }
+
+func (ProtobufSerializer) ContentType() string { return "application/x-protobuf" }
+
+func (ProtobufSerializer) Serialize(envelope Envelope) ([]byte, error) {
+	message, ok := envelope.Data.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("kafka: %s event %T is not a proto.Message", envelope.Type, envelope.Data)
+	}
+
+	return proto.Marshal(message)
+}
+
+// Encoder builds the messages of events after checking them against the
+// schemas.
+type Encoder struct {
+	Source     string
+	Serializer Serializer
+	Schemas    *SchemaRegistry
+}
+
+// NewEncoder serializes to JSON and checks against the default registry.
+func NewEncoder(source string) *Encoder {
+	return &Encoder{Source: source, Serializer: JSONSerializer{}, Schemas: DefaultSchemas}
+}
+
+// Encode returns the message publishing event to topic, keyed by its
+// PartitionKey, and its envelope.
+func (e *Encoder) Encode(ctx context.Context, topic string, event Event) (*sarama.ProducerMessage, Envelope, error) {
+	envelope := NewEnvelope(ctx, e.Source, event)
+
+	if err := e.Schemas.Check(envelope, e.Serializer.Format()); err != nil {
+		return nil, envelope, err
+	}
+
+	value, err := e.Serializer.Serialize(envelope)
+	if err != nil {
+		return nil, envelope, err
+	}
+
+	message := &sarama.ProducerMessage{
+		Topic:   topic,
+		Value:   sarama.ByteEncoder(value),
+		Headers: envelope.Headers(e.Serializer.ContentType()),
+	}
+
+	if key := event.PartitionKey(); key != "" {
+		message.Key = sarama.StringEncoder(key)
+	}
+
+	return message, envelope, nil
+}
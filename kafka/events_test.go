@@ -0,0 +1,77 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"restapi/logger"
+)
+
+type testEvent struct {
+	Amount int `json:"Amount"`
+}
+
+func (testEvent) EventType() string    { return "test.happened" }
+func (testEvent) EventVersion() int    { return 2 }
+func (testEvent) PartitionKey() string { return "company-7" }
+
+type otherEvent struct{ testEvent }
+
+func TestEncoder_Encode(t *testing.T) {
+	schemas := NewSchemaRegistry()
+	encoder := &Encoder{Source: "tests", Serializer: JSONSerializer{}, Schemas: schemas}
+
+	ctx := context.WithValue(context.Background(), logger.TransactionIDKey, "req-1")
+
+	if _, _, err := encoder.Encode(ctx, "events", testEvent{Amount: 3}); err == nil {
+		t.Fatal("expected unregistered events to be rejected")
+	}
+
+	if err := schemas.Register(Schema{Type: "test.happened", Version: 2, Format: FormatJSON, Prototype: testEvent{}}); err != nil {
+		t.Fatalf("Register: %s", err)
+	}
+
+	if err := schemas.Register(Schema{Type: "test.happened", Version: 2, Format: FormatJSON, Prototype: testEvent{}}); err == nil {
+		t.Error("expected a version to be registered once")
+	}
+
+	message, envelope, err := encoder.Encode(ctx, "events", testEvent{Amount: 3})
+	if err != nil {
+		t.Fatalf("Encode: %s", err)
+	}
+
+	if key, _ := message.Key.Encode(); string(key) != "company-7" {
+		t.Errorf("got key %s", key)
+	}
+
+	headers := make(map[string]string)
+	for _, header := range message.Headers {
+		headers[string(header.Key)] = string(header.Value)
+	}
+
+	if headers[HeaderEventID] != envelope.ID || headers[HeaderVersion] != "2" || headers[RequestIDHeader] != "req-1" {
+		t.Errorf("unexpected headers %v", headers)
+	}
+
+	value, _ := message.Value.Encode()
+
+	var decoded struct {
+		Type          string    `json:"type"`
+		CorrelationID string    `json:"correlationId"`
+		Data          testEvent `json:"data"`
+	}
+
+	if err := json.Unmarshal(value, &decoded); err != nil || decoded.Type != "test.happened" || decoded.CorrelationID != "req-1" || decoded.Data.Amount != 3 {
+		t.Errorf("unexpected value %s", value)
+	}
+
+	if _, _, err := encoder.Encode(ctx, "events", otherEvent{}); err == nil {
+		t.Error("expected an event of another Go type to be rejected")
+	}
+
+	encoder.Serializer = ProtobufSerializer{}
+	if _, _, err := encoder.Encode(ctx, "events", testEvent{}); err == nil {
+		t.Error("expected a schema of another format to be rejected")
+	}
+}
@@ -0,0 +1,31 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// PingBrokers succeeds as soon as one of the brokers accepts a connection,
+// which is all a client needs to bootstrap.
+func PingBrokers(ctx context.Context, brokers []string) error {
+	if len(brokers) == 0 {
+		return errors.New("no kafka brokers configured")
+	}
+
+	var (
+		dialer  net.Dialer
+		lastErr error
+	)
+
+	for _, broker := range brokers {
+		conn, err := dialer.DialContext(ctx, "tcp", broker)
+		if err == nil {
+			return conn.Close()
+		}
+
+		lastErr = err
+	}
+
+	return lastErr
+}
@@ -0,0 +1,50 @@
+package kafka
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	messagesProduced = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_messages_produced_total",
+		Help: "Messages successfully produced by topic.",
+	}, []string{"topic"})
+	produceErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_produce_errors_total",
+		Help: "Messages that could not be produced by topic.",
+	}, []string{"topic"})
+	messagesConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_messages_consumed_total",
+		Help: "Messages handed to a processor by topic.",
+	}, []string{"topic"})
+	consumeErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_consume_errors_total",
+		Help: "Messages a processor failed on by topic.",
+	}, []string{"topic"})
+	consumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kafka_consumer_lag",
+		Help: "Messages between the last consumed offset and the partition high water mark.",
+	}, []string{"topic", "partition"})
+)
+
+func recordProduce(topic string, err error) {
+	if err != nil {
+		produceErrors.WithLabelValues(topic).Inc()
+
+		return
+	}
+
+	messagesProduced.WithLabelValues(topic).Inc()
+}
+
+func recordLag(topic string, partition int32, highWaterMark int64, offset int64) {
+	lag := highWaterMark - offset - 1
+	if lag < 0 {
+		lag = 0
+	}
+
+	consumerLag.WithLabelValues(topic, strconv.Itoa(int(partition))).Set(float64(lag))
+}
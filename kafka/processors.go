@@ -0,0 +1,69 @@
+package kafka
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"restapi/logger"
+)
+
+var (
+	processorsMu sync.RWMutex
+	processors   = make(map[string]Processor)
+)
+
+// RegisterProcessor makes a processor available to the consume command
+// under name.
+func RegisterProcessor(name string, processor Processor) {
+	processorsMu.Lock()
+	defer processorsMu.Unlock()
+
+	if _, ok := processors[name]; ok {
+		panic("kafka: processor " + name + " registered twice")
+	}
+
+	processors[name] = processor
+}
+
+func LookupProcessor(name string) (Processor, bool) {
+	processorsMu.RLock()
+	defer processorsMu.RUnlock()
+
+	processor, ok := processors[name]
+
+	return processor, ok
+}
+
+// ProcessorNames lists the registered processors, sorted.
+func ProcessorNames() []string {
+	processorsMu.RLock()
+	defer processorsMu.RUnlock()
+
+	names := make([]string, 0, len(processors))
+	for name := range processors {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// LogProcessor only logs what it receives, it is handy to inspect a topic.
+type LogProcessor struct{}
+
+func (LogProcessor) Process(ctx context.Context, message string, timestamp time.Time, topic string) error {
+	logger.Info(ctx, "received message", logger.Z{
+		"topic":     topic,
+		"message":   message,
+		"timestamp": timestamp,
+	})
+
+	return nil
+}
+
+func init() {
+	RegisterProcessor("log", LogProcessor{})
+}
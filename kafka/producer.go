@@ -2,16 +2,21 @@ package kafka
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
-	"strings"
+	"sync"
 	"time"
 
-	helpers "restapi/util"
+	"restapi/config"
+	"restapi/logger"
 
 	"github.com/IBM/sarama"
 )
 
+// RequestIDHeader is the message header carrying the correlation id, it
+// matches the HTTP header of the same name.
+const RequestIDHeader = "X-Request-ID"
+
 type Processor interface {
 	Process(context.Context, string, time.Time, string) error
 }
@@ -30,28 +35,105 @@ type ConfigParams struct {
 	Partitioner  sarama.PartitionerConstructor
 	RequiredAcks sarama.RequiredAcks
 	Successes    bool
+
+	// Async makes an async producer, which sends messages in batches of
+	// up to FlushMessages messages or FlushBytes bytes, at least every
+	// FlushFrequency. Zero values keep the sarama defaults.
+	Async          bool
+	FlushBytes     int
+	FlushMessages  int
+	FlushFrequency time.Duration
+	// Compression compresses batches, e.g. sarama.CompressionSnappy,
+	// CompressionLZ4 or CompressionZSTD
+	Compression sarama.CompressionCodec
+	// Idempotent lets the brokers drop the duplicates of retried messages,
+	// it implies acks from all replicas and one request in flight
+	Idempotent bool
+	// OnSuccess and OnError are told the outcome of every message sent
+	// with SendAsync, from goroutines of the producer
+	OnSuccess func(message *sarama.ProducerMessage)
+	OnError   func(err *sarama.ProducerError)
 }
+
+// Producer sends messages to Kafka. Client is set for sync producers and
+// Async for async ones, Close flushes and closes either.
 type Producer struct {
 	Client sarama.SyncProducer
+	Async  sarama.AsyncProducer
 	Topic  string
+
+	onSuccess func(message *sarama.ProducerMessage)
+	onError   func(err *sarama.ProducerError)
+
+	mu      sync.RWMutex
+	closed  bool
+	drained sync.WaitGroup
 }
 
+// ErrProducerClosed is returned for messages sent after Close.
+var ErrProducerClosed = errors.New("kafka: producer is closed")
+
+// delivery is the Metadata of messages SendMessage waits for on an async
+// producer.
+type delivery chan error
+
 func NewProducer(
 	env string,
 	prefix string,
 	configParams ConfigParams,
 ) (*Producer, error) {
-	// load env if not already loaded
-	if len(os.Getenv(prefix+"_KAFKA_VERSION")) == 0 {
-		helpers.LoadEnv(env)
+	cfg, err := config.Load(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProducerFromConfig(cfg.KafkaCluster(prefix), configParams)
+}
+
+// NewProducerFromConfig connects a producer to the cluster of cfg,
+// messages go to cfg.Topic.
+func NewProducerFromConfig(cfg config.Kafka, configParams ConfigParams) (*Producer, error) {
+	config, err := newProducerConfig(cfg, configParams)
+	if err != nil {
+		return nil, err
 	}
 
+	if !configParams.Async {
+		client, err := sarama.NewSyncProducer(cfg.Brokers, config)
+		if err != nil {
+			return nil, fmt.Errorf("error intializing Kafka producer: %w", err)
+		}
+
+		return &Producer{Client: client, Topic: cfg.Topic, onSuccess: configParams.OnSuccess, onError: configParams.OnError}, nil
+	}
+
+	async, err := sarama.NewAsyncProducer(cfg.Brokers, config)
+	if err != nil {
+		return nil, fmt.Errorf("error intializing Kafka producer: %w", err)
+	}
+
+	return newAsyncProducer(async, cfg.Topic, configParams), nil
+}
+
+// newAsyncProducer wraps async and starts draining its results.
+func newAsyncProducer(async sarama.AsyncProducer, topic string, configParams ConfigParams) *Producer {
+	producer := &Producer{Async: async, Topic: topic, onSuccess: configParams.OnSuccess, onError: configParams.OnError}
+
+	producer.drained.Add(2)
+
+	go producer.drainSuccesses()
+	go producer.drainErrors()
+
+	return producer
+}
+
+func newProducerConfig(cfg config.Kafka, configParams ConfigParams) (*sarama.Config, error) {
 	/**
 	 * Construct a new Sarama configuration.
 	 * The Kafka cluster version has to be defined before the consumer is initialized.
 	 */
 
-	version, err := sarama.ParseKafkaVersion(os.Getenv(prefix + "_KAFKA_VERSION"))
+	version, err := sarama.ParseKafkaVersion(cfg.Version)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing Kafka version: %w", err)
 	}
@@ -64,7 +146,7 @@ func NewProducer(
 	config.Producer.RequiredAcks = sarama.WaitForLocal
 	config.Producer.Return.Successes = true
 
-	if configParams.Version.String() != "" {
+	if configParams.Version != (sarama.KafkaVersion{}) {
 		config.Version = configParams.Version
 	}
 
@@ -80,13 +162,186 @@ func NewProducer(
 		config.Producer.Return.Successes = configParams.Successes
 	}
 
-	brokers := os.Getenv(prefix + "_KAFKA_BROKER")
+	if configParams.FlushBytes > 0 {
+		config.Producer.Flush.Bytes = configParams.FlushBytes
+	}
+
+	if configParams.FlushMessages > 0 {
+		config.Producer.Flush.Messages = configParams.FlushMessages
+	}
+
+	if configParams.FlushFrequency > 0 {
+		config.Producer.Flush.Frequency = configParams.FlushFrequency
+	}
+
+	config.Producer.Compression = configParams.Compression
+
+	if configParams.Idempotent {
+		config.Producer.Idempotent = true
+		config.Producer.RequiredAcks = sarama.WaitForAll
+		config.Net.MaxOpenRequests = 1
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid Kafka producer settings: %w", err)
+	}
+
+	return config, nil
+}
+
+// NewMessage builds a message for topic that carries the correlation id
+// found in ctx as a header.
+func NewMessage(ctx context.Context, topic string, key []byte, value []byte) *sarama.ProducerMessage {
+	message := &sarama.ProducerMessage{
+		Topic: topic,
+		Value: sarama.ByteEncoder(value),
+	}
+
+	if key != nil {
+		message.Key = sarama.ByteEncoder(key)
+	}
+
+	if id := logger.TransactionID(ctx); id != "" {
+		message.Headers = append(message.Headers, sarama.RecordHeader{
+			Key:   []byte(RequestIDHeader),
+			Value: []byte(id),
+		})
+	}
+
+	return message
+}
+
+// Send publishes value to the producer topic, tagged with the correlation id
+// of ctx.
+func (p *Producer) Send(ctx context.Context, key []byte, value []byte) (int32, int64, error) {
+	return p.SendMessage(NewMessage(ctx, p.Topic, key, value))
+}
+
+// SendMessage publishes a message built by the caller, e.g. with
+// NewMessage, and waits for the brokers to acknowledge it. On an async
+// producer it replaces the Metadata of message.
+func (p *Producer) SendMessage(message *sarama.ProducerMessage) (int32, int64, error) {
+	if p.Async == nil {
+		partition, offset, err := p.Client.SendMessage(message)
+
+		recordProduce(message.Topic, err)
+
+		return partition, offset, err
+	}
+
+	done := make(delivery, 1)
+	message.Metadata = done
+
+	if err := p.enqueue(message); err != nil {
+		return 0, 0, err
+	}
 
-	producer, err := sarama.NewSyncProducer(strings.Split(brokers, ","), config)
+	err := <-done
 
+	return message.Partition, message.Offset, err
+}
+
+// SendAsync hands message to an async producer and returns, OnSuccess or
+// OnError learn what became of it. A sync producer sends it right away
+// and calls them before returning.
+func (p *Producer) SendAsync(message *sarama.ProducerMessage) error {
+	if p.Async != nil {
+		return p.enqueue(message)
+	}
+
+	partition, offset, err := p.SendMessage(message)
 	if err != nil {
-		return nil, fmt.Errorf("error intializing Kafka producer: %w", err)
+		p.failed(&sarama.ProducerError{Msg: message, Err: err})
+
+		return nil
 	}
 
-	return &Producer{Client: producer, Topic: os.Getenv(prefix + "_KAFKA_TOPIC")}, nil
+	message.Partition, message.Offset = partition, offset
+	p.succeeded(message)
+
+	return nil
+}
+
+func (p *Producer) enqueue(message *sarama.ProducerMessage) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrProducerClosed
+	}
+
+	p.Async.Input() <- message
+
+	return nil
+}
+
+func (p *Producer) drainSuccesses() {
+	defer p.drained.Done()
+
+	for message := range p.Async.Successes() {
+		recordProduce(message.Topic, nil)
+
+		if done, ok := message.Metadata.(delivery); ok {
+			done <- nil
+		}
+
+		p.succeeded(message)
+	}
+}
+
+func (p *Producer) drainErrors() {
+	defer p.drained.Done()
+
+	for err := range p.Async.Errors() {
+		recordProduce(err.Msg.Topic, err.Err)
+
+		if done, ok := err.Msg.Metadata.(delivery); ok {
+			done <- err.Err
+		}
+
+		p.failed(err)
+	}
+}
+
+func (p *Producer) succeeded(message *sarama.ProducerMessage) {
+	if p.onSuccess != nil {
+		p.onSuccess(message)
+	}
+}
+
+// failed tells OnError about err, or logs it when there is none.
+func (p *Producer) failed(err *sarama.ProducerError) {
+	if p.onError != nil {
+		p.onError(err)
+
+		return
+	}
+
+	logger.Error(context.Background(), "unable to produce message", logger.Z{
+		"error": err.Err.Error(),
+		"topic": err.Msg.Topic,
+	})
+}
+
+// Close flushes the messages an async producer still holds, waits until
+// their callbacks ran and closes the producer.
+func (p *Producer) Close() error {
+	if p.Async == nil {
+		return p.Client.Close()
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+
+		return nil
+	}
+
+	p.closed = true
+	p.mu.Unlock()
+
+	p.Async.AsyncClose()
+	p.drained.Wait()
+
+	return nil
 }
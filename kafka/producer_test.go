@@ -0,0 +1,85 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"restapi/config"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+)
+
+func TestProducer_Async(t *testing.T) {
+	config := mocks.NewTestConfig()
+	config.Producer.Return.Successes = true
+
+	mock := mocks.NewAsyncProducer(t, config)
+	mock.ExpectInputAndSucceed()
+	mock.ExpectInputAndFail(errors.New("broker down"))
+	mock.ExpectInputAndSucceed()
+
+	var succeeded, failed atomic.Int32
+
+	producer := newAsyncProducer(mock, "events", ConfigParams{
+		OnSuccess: func(*sarama.ProducerMessage) { succeeded.Add(1) },
+		OnError:   func(*sarama.ProducerError) { failed.Add(1) },
+	})
+
+	if err := producer.SendAsync(&sarama.ProducerMessage{Topic: "events", Value: sarama.StringEncoder("a")}); err != nil {
+		t.Fatalf("SendAsync: %s", err)
+	}
+
+	if _, _, err := producer.SendMessage(&sarama.ProducerMessage{Topic: "events", Value: sarama.StringEncoder("b")}); err == nil {
+		t.Error("expected SendMessage to wait for the failure")
+	}
+
+	if err := producer.SendAsync(&sarama.ProducerMessage{Topic: "events", Value: sarama.StringEncoder("c")}); err != nil {
+		t.Fatalf("SendAsync: %s", err)
+	}
+
+	if err := producer.Close(); err != nil {
+		t.Fatalf("Close: %s", err)
+	}
+
+	if succeeded.Load() != 2 || failed.Load() != 1 {
+		t.Errorf("Close returned before the callbacks ran: %d succeeded, %d failed", succeeded.Load(), failed.Load())
+	}
+
+	if err := producer.SendAsync(&sarama.ProducerMessage{Topic: "events"}); !errors.Is(err, ErrProducerClosed) {
+		t.Errorf("got %v after Close", err)
+	}
+}
+
+func TestProducer_SendOnAsync(t *testing.T) {
+	config := mocks.NewTestConfig()
+	config.Producer.Return.Successes = true
+
+	mock := mocks.NewAsyncProducer(t, config)
+	mock.ExpectInputAndSucceed()
+
+	producer := newAsyncProducer(mock, "events", ConfigParams{})
+
+	if _, _, err := producer.Send(context.Background(), []byte("key"), []byte("value")); err != nil {
+		t.Errorf("Send: %s", err)
+	}
+
+	if err := producer.Close(); err != nil {
+		t.Fatalf("Close: %s", err)
+	}
+}
+
+func TestNewProducerConfig_Version(t *testing.T) {
+	cfg := config.Kafka{Prefix: "TEST", Version: "3.6.0", Brokers: []string{"localhost:9092"}}
+
+	producerConfig, err := newProducerConfig(cfg, ConfigParams{Idempotent: true, Compression: sarama.CompressionZSTD})
+	if err != nil {
+		t.Fatalf("idempotent zstd producer rejected: %s", err)
+	}
+
+	if producerConfig.Version != sarama.V3_6_0_0 {
+		t.Errorf("got version %s, want %s", producerConfig.Version, sarama.V3_6_0_0)
+	}
+}
@@ -0,0 +1,203 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"restapi/logger"
+
+	"github.com/IBM/sarama"
+)
+
+const (
+	defaultBatchSize     = 100
+	defaultFlushInterval = time.Second
+)
+
+// ErrPublisherClosed is returned by PublishAsync after Close.
+var ErrPublisherClosed = errors.New("kafka: publisher is closed")
+
+// PublisherOptions configure a Publisher, zero values pick the defaults.
+type PublisherOptions struct {
+	// Topic receives the events, it defaults to the topic of the producer
+	Topic string
+	// Encoder defaults to NewEncoder(Source)
+	Encoder *Encoder
+	Source  string
+	// BatchSize and FlushInterval bound how long PublishAsync holds events
+	// before sending them together
+	BatchSize     int
+	FlushInterval time.Duration
+	// OnError is told about events PublishAsync failed to send through a
+	// sync producer, they are logged by default
+	OnError func(message *sarama.ProducerMessage, err error)
+}
+
+// Publisher sends typed events through a Producer, either one at a time
+// with Publish or batched in the background with PublishAsync.
+type Publisher struct {
+	producer *Producer
+	encoder  *Encoder
+	topic    string
+	opts     PublisherOptions
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan *sarama.ProducerMessage
+	done   chan struct{}
+}
+
+func NewPublisher(producer *Producer, opts PublisherOptions) *Publisher {
+	if opts.Topic == "" {
+		opts.Topic = producer.Topic
+	}
+
+	if opts.Encoder == nil {
+		opts.Encoder = NewEncoder(opts.Source)
+	}
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultFlushInterval
+	}
+
+	if opts.OnError == nil {
+		opts.OnError = func(message *sarama.ProducerMessage, err error) {
+			logger.Error(context.Background(), "unable to publish event", logger.Z{"error": err.Error(), "topic": message.Topic})
+		}
+	}
+
+	publisher := &Publisher{
+		producer: producer,
+		encoder:  opts.Encoder,
+		topic:    opts.Topic,
+		opts:     opts,
+		queue:    make(chan *sarama.ProducerMessage, opts.BatchSize),
+		done:     make(chan struct{}),
+	}
+
+	// async producers batch by themselves
+	if producer.Async != nil {
+		close(publisher.done)
+	} else {
+		go publisher.batch()
+	}
+
+	return publisher
+}
+
+// Publish sends event and waits for the brokers to acknowledge it.
+func (p *Publisher) Publish(ctx context.Context, event Event) (Envelope, error) {
+	message, envelope, err := p.encoder.Encode(ctx, p.topic, event)
+	if err != nil {
+		return envelope, err
+	}
+
+	_, _, err = p.producer.SendMessage(message)
+
+	return envelope, err
+}
+
+// PublishAsync queues event and returns once it is encoded, events are
+// sent in batches of BatchSize or every FlushInterval. Failures are
+// reported to OnError. It blocks while a full batch is being sent. With an
+// async producer the producer batches instead and its OnError is told.
+func (p *Publisher) PublishAsync(ctx context.Context, event Event) (Envelope, error) {
+	message, envelope, err := p.encoder.Encode(ctx, p.topic, event)
+	if err != nil {
+		return envelope, err
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return envelope, ErrPublisherClosed
+	}
+
+	if p.producer.Async != nil {
+		return envelope, p.producer.SendAsync(message)
+	}
+
+	p.queue <- message
+
+	return envelope, nil
+}
+
+// Close sends the queued events and stops the background sender, it does
+// not close the producer.
+func (p *Publisher) Close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	<-p.done
+}
+
+func (p *Publisher) batch() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.opts.FlushInterval)
+	defer ticker.Stop()
+
+	pending := make([]*sarama.ProducerMessage, 0, p.opts.BatchSize)
+
+	for {
+		select {
+		case message, ok := <-p.queue:
+			if !ok {
+				p.flush(pending)
+
+				return
+			}
+
+			pending = append(pending, message)
+			if len(pending) >= p.opts.BatchSize {
+				p.flush(pending)
+				pending = pending[:0]
+			}
+		case <-ticker.C:
+			p.flush(pending)
+			pending = pending[:0]
+		}
+	}
+}
+
+func (p *Publisher) flush(messages []*sarama.ProducerMessage) {
+	if len(messages) == 0 {
+		return
+	}
+
+	err := p.producer.Client.SendMessages(messages)
+
+	failed := make(map[*sarama.ProducerMessage]bool)
+
+	var errs sarama.ProducerErrors
+	if errors.As(err, &errs) {
+		for _, producerErr := range errs {
+			failed[producerErr.Msg] = true
+			p.opts.OnError(producerErr.Msg, producerErr.Err)
+		}
+	} else if err != nil {
+		for _, message := range messages {
+			failed[message] = true
+			p.opts.OnError(message, err)
+		}
+	}
+
+	for _, message := range messages {
+		if failed[message] {
+			produceErrors.WithLabelValues(message.Topic).Inc()
+		} else {
+			messagesProduced.WithLabelValues(message.Topic).Inc()
+		}
+	}
+}
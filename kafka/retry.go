@@ -0,0 +1,291 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"restapi/logger"
+
+	"github.com/IBM/sarama"
+)
+
+// Headers of messages forwarded to retry topics and the dead-letter queue.
+const (
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+	// HeaderAttempt counts the times the message was processed
+	HeaderAttempt = "x-attempt"
+	// HeaderRetries counts the retry topics the message went through
+	HeaderRetries   = "x-retries"
+	HeaderLastError = "x-last-error"
+	// HeaderNotBefore is when, in unix milliseconds, a retry is due
+	HeaderNotBefore = "x-not-before"
+)
+
+const (
+	retrySuffix = ".retry."
+	dlqSuffix   = ".dlq"
+
+	maxErrorHeaderLength = 1024
+)
+
+// RetryPolicy decides what happens to messages the processor fails on.
+// Each delivery is processed up to Attempts times, waiting Backoff and
+// then twice as long before each retry, up to MaxBackoff. A message still
+// failing goes to the next retry topic of Tiers, e.g. "orders.retry.1m"
+// for a tier of a minute, where it is processed again once the delay
+// passed, and after the last tier to "orders.dlq" when DLQ is set.
+// Forwarding needs Producer, without one failed messages are logged and
+// skipped.
+type RetryPolicy struct {
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Tiers      []time.Duration
+	DLQ        bool
+	Producer   *Producer
+}
+
+// RetryTopic is the topic of the tier of topic delayed by delay.
+func RetryTopic(topic string, delay time.Duration) string {
+	return topic + retrySuffix + durationName(delay)
+}
+
+// DLQTopic is the dead-letter queue of topic.
+func DLQTopic(topic string) string {
+	return topic + dlqSuffix
+}
+
+// Topics are topics followed by their retry topics, which the consumer
+// group has to subscribe to as well.
+func (p *RetryPolicy) Topics(topics []string) []string {
+	if p == nil {
+		return topics
+	}
+
+	all := append([]string(nil), topics...)
+
+	for _, topic := range topics {
+		for _, delay := range p.Tiers {
+			all = append(all, RetryTopic(topic, delay))
+		}
+	}
+
+	return all
+}
+
+// retryState is what the headers of a message say about its past.
+type retryState struct {
+	topic     string
+	partition int32
+	offset    int64
+	attempts  int
+	retries   int
+	notBefore time.Time
+}
+
+func stateOf(message *sarama.ConsumerMessage) retryState {
+	state := retryState{topic: message.Topic, partition: message.Partition, offset: message.Offset}
+
+	for _, header := range message.Headers {
+		if header == nil {
+			continue
+		}
+
+		value := string(header.Value)
+
+		switch string(header.Key) {
+		case HeaderOriginalTopic:
+			state.topic = value
+		case HeaderOriginalPartition:
+			if partition, err := strconv.ParseInt(value, 10, 32); err == nil {
+				state.partition = int32(partition)
+			}
+		case HeaderOriginalOffset:
+			if offset, err := strconv.ParseInt(value, 10, 64); err == nil {
+				state.offset = offset
+			}
+		case HeaderAttempt:
+			state.attempts, _ = strconv.Atoi(value)
+		case HeaderRetries:
+			state.retries, _ = strconv.Atoi(value)
+		case HeaderNotBefore:
+			if millis, err := strconv.ParseInt(value, 10, 64); err == nil {
+				state.notBefore = time.UnixMilli(millis)
+			}
+		}
+	}
+
+	return state
+}
+
+// handle processes message by the policy. It only returns an error when
+// the message must not be marked: ctx ended or it could not be forwarded.
+func (p *RetryPolicy) handle(ctx context.Context, message *sarama.ConsumerMessage, processor Processor) error {
+	state := stateOf(message)
+
+	if wait := time.Until(state.notBefore); wait > 0 {
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	attempts, err := p.process(ctx, message, state.topic, processor)
+	if err == nil {
+		return nil
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	consumeErrors.WithLabelValues(message.Topic).Inc()
+
+	state.attempts += attempts
+
+	destination := ""
+	if state.retries < len(p.Tiers) {
+		destination = RetryTopic(state.topic, p.Tiers[state.retries])
+	} else if p.DLQ {
+		destination = DLQTopic(state.topic)
+	}
+
+	logger.Error(ctx, "Could not Process message", logger.Z{
+		"error":       err.Error(),
+		"topic":       state.topic,
+		"partition":   state.partition,
+		"offset":      state.offset,
+		"attempts":    state.attempts,
+		"destination": destination,
+	})
+
+	if destination == "" || p.Producer == nil {
+		return nil
+	}
+
+	_, _, sendErr := p.Producer.SendMessage(p.forward(message, state, destination, err))
+	if sendErr != nil {
+		return fmt.Errorf("kafka: forwarding offset %d of %s/%d to %s: %w", message.Offset, message.Topic, message.Partition, destination, sendErr)
+	}
+
+	return nil
+}
+
+// process runs processor up to Attempts times and returns how often it ran.
+func (p *RetryPolicy) process(ctx context.Context, message *sarama.ConsumerMessage, topic string, processor Processor) (int, error) {
+	backoff := p.Backoff
+
+	for attempt := 1; ; attempt++ {
+		err := processor.Process(ctx, string(message.Value), message.Timestamp, topic)
+		if err == nil || attempt >= p.Attempts {
+			return attempt, err
+		}
+
+		consumeErrors.WithLabelValues(message.Topic).Inc()
+
+		timer := time.NewTimer(backoff)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return attempt, errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+
+		if backoff *= 2; p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
+
+// forward builds the copy of message sent to destination, recording where
+// it came from and why it failed.
+func (p *RetryPolicy) forward(message *sarama.ConsumerMessage, state retryState, destination string, cause error) *sarama.ProducerMessage {
+	// cut on a character boundary, a split character is not valid UTF-8
+	lastError := []rune(strings.ToValidUTF8(cause.Error(), "\uFFFD"))
+	if len(lastError) > maxErrorHeaderLength {
+		lastError = lastError[:maxErrorHeaderLength]
+	}
+
+	forwarded := &sarama.ProducerMessage{
+		Topic:   destination,
+		Value:   sarama.ByteEncoder(message.Value),
+		Headers: withoutRetryHeaders(message.Headers),
+	}
+
+	if message.Key != nil {
+		forwarded.Key = sarama.ByteEncoder(message.Key)
+	}
+
+	retries := state.retries
+	if retries < len(p.Tiers) {
+		retries++
+	}
+
+	addHeader(forwarded, HeaderOriginalTopic, state.topic)
+	addHeader(forwarded, HeaderOriginalPartition, strconv.Itoa(int(state.partition)))
+	addHeader(forwarded, HeaderOriginalOffset, strconv.FormatInt(state.offset, 10))
+	addHeader(forwarded, HeaderAttempt, strconv.Itoa(state.attempts))
+	addHeader(forwarded, HeaderRetries, strconv.Itoa(retries))
+	addHeader(forwarded, HeaderLastError, string(lastError))
+
+	if state.retries < len(p.Tiers) {
+		addHeader(forwarded, HeaderNotBefore, strconv.FormatInt(time.Now().Add(p.Tiers[state.retries]).UnixMilli(), 10))
+	}
+
+	return forwarded
+}
+
+func addHeader(message *sarama.ProducerMessage, name string, value string) {
+	message.Headers = append(message.Headers, sarama.RecordHeader{Key: []byte(name), Value: []byte(value)})
+}
+
+// withoutRetryHeaders copies headers, leaving out those of RetryPolicy.
+func withoutRetryHeaders(headers []*sarama.RecordHeader) []sarama.RecordHeader {
+	kept := make([]sarama.RecordHeader, 0, len(headers))
+
+	for _, header := range headers {
+		if header == nil || isRetryHeader(string(header.Key)) {
+			continue
+		}
+
+		kept = append(kept, *header)
+	}
+
+	return kept
+}
+
+func isRetryHeader(name string) bool {
+	switch name {
+	case HeaderOriginalTopic, HeaderOriginalPartition, HeaderOriginalOffset, HeaderAttempt, HeaderRetries, HeaderLastError, HeaderNotBefore:
+		return true
+	default:
+		return false
+	}
+}
+
+// durationName spells delay the way topics are named, e.g. 1m or 30s.
+func durationName(delay time.Duration) string {
+	switch {
+	case delay%time.Hour == 0:
+		return strconv.Itoa(int(delay/time.Hour)) + "h"
+	case delay%time.Minute == 0:
+		return strconv.Itoa(int(delay/time.Minute)) + "m"
+	case delay%time.Second == 0:
+		return strconv.Itoa(int(delay/time.Second)) + "s"
+	default:
+		return strconv.FormatInt(delay.Milliseconds(), 10) + "ms"
+	}
+}
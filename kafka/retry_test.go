@@ -0,0 +1,128 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+)
+
+type failingProcessor struct {
+	calls int
+}
+
+func (p *failingProcessor) Process(context.Context, string, time.Time, string) error {
+	p.calls++
+
+	return errors.New("not yet")
+}
+
+func TestRetryPolicy_Topics(t *testing.T) {
+	policy := &RetryPolicy{Tiers: []time.Duration{30 * time.Second, time.Minute, 2 * time.Hour}}
+
+	got := policy.Topics([]string{"orders"})
+	want := []string{"orders", "orders.retry.30s", "orders.retry.1m", "orders.retry.2h"}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+
+	var none *RetryPolicy
+	if got := none.Topics([]string{"orders"}); len(got) != 1 {
+		t.Errorf("nil policy subscribed to %v", got)
+	}
+}
+
+func TestRetryPolicy_handle(t *testing.T) {
+	var sent []*sarama.ProducerMessage
+
+	capture := func(message *sarama.ProducerMessage) error {
+		sent = append(sent, message)
+
+		return nil
+	}
+
+	mock := mocks.NewSyncProducer(t, mocks.NewTestConfig())
+	mock.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(capture)
+	mock.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(capture)
+
+	policy := &RetryPolicy{
+		Attempts: 2,
+		Backoff:  time.Millisecond,
+		Tiers:    []time.Duration{time.Minute},
+		DLQ:      true,
+		Producer: &Producer{Client: mock},
+	}
+
+	processor := &failingProcessor{}
+
+	message := &sarama.ConsumerMessage{
+		Topic:     "orders",
+		Partition: 3,
+		Offset:    42,
+		Key:       []byte("company-1"),
+		Value:     []byte(`{"id":1}`),
+		Headers:   []*sarama.RecordHeader{{Key: []byte(HeaderEventType), Value: []byte("order.created")}},
+	}
+
+	if err := policy.handle(context.Background(), message, processor); err != nil {
+		t.Fatalf("handle: %s", err)
+	}
+
+	if processor.calls != 2 || len(sent) != 1 || sent[0].Topic != "orders.retry.1m" {
+		t.Fatalf("expected 2 attempts and a retry, got %d attempts and %v", processor.calls, sent)
+	}
+
+	// the retry comes back from the retry topic once it is due
+	retried := &sarama.ConsumerMessage{Topic: sent[0].Topic, Key: message.Key, Value: message.Value}
+	for _, header := range sent[0].Headers {
+		if string(header.Key) != HeaderNotBefore {
+			retried.Headers = append(retried.Headers, &sarama.RecordHeader{Key: header.Key, Value: header.Value})
+		}
+	}
+
+	if err := policy.handle(context.Background(), retried, processor); err != nil {
+		t.Fatalf("handle: %s", err)
+	}
+
+	if len(sent) != 2 || sent[1].Topic != "orders.dlq" {
+		t.Fatalf("expected the dead-letter queue, got %v", sent)
+	}
+
+	key, _ := sent[1].Key.Encode()
+	if string(key) != "company-1" {
+		t.Errorf("key %q was not kept", key)
+	}
+
+	state := stateOf(&sarama.ConsumerMessage{Topic: sent[1].Topic, Headers: headerPointers(sent[1].Headers)})
+	if state.topic != "orders" || state.partition != 3 || state.offset != 42 || state.attempts != 4 || state.retries != 1 {
+		t.Errorf("unexpected state %+v", state)
+	}
+
+	kept := withoutRetryHeaders(headerPointers(sent[1].Headers))
+	if len(kept) != 1 || string(kept[0].Key) != HeaderEventType {
+		t.Errorf("headers of the event were not kept: %v", kept)
+	}
+
+	if err := mock.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func headerPointers(headers []sarama.RecordHeader) []*sarama.RecordHeader {
+	pointers := make([]*sarama.RecordHeader, len(headers))
+	for i := range headers {
+		pointers[i] = &headers[i]
+	}
+
+	return pointers
+}
@@ -0,0 +1,104 @@
+package kafka
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// Schema declares version Version of the events of type Type, serialized
+// as Format. Prototype is a zero event of the Go type carrying it.
+type Schema struct {
+	Type      string
+	Version   int
+	Format    Format
+	Prototype Event
+}
+
+// SchemaRegistry stands in for a schema registry: publishers may only send
+// events whose type, version and format were registered, with the Go type
+// the version was registered with.
+type SchemaRegistry struct {
+	mu      sync.RWMutex
+	schemas map[string]map[int]Schema
+}
+
+// DefaultSchemas is the registry of NewEncoder, packages register the
+// events they publish with RegisterSchema.
+var DefaultSchemas = NewSchemaRegistry()
+
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{schemas: make(map[string]map[int]Schema)}
+}
+
+// RegisterSchema adds schema to DefaultSchemas and panics when it cannot,
+// it is meant for init functions.
+func RegisterSchema(schema Schema) {
+	if err := DefaultSchemas.Register(schema); err != nil {
+		panic(err)
+	}
+}
+
+// Register adds schema. A version cannot be registered twice, new event
+// shapes need a new version.
+func (r *SchemaRegistry) Register(schema Schema) error {
+	if schema.Type == "" || schema.Version < 1 || schema.Format == "" || schema.Prototype == nil {
+		return fmt.Errorf("kafka: schema %s v%d needs a type, a positive version, a format and a prototype", schema.Type, schema.Version)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	versions, ok := r.schemas[schema.Type]
+	if !ok {
+		versions = make(map[int]Schema)
+		r.schemas[schema.Type] = versions
+	}
+
+	if _, ok := versions[schema.Version]; ok {
+		return fmt.Errorf("kafka: schema %s v%d registered twice", schema.Type, schema.Version)
+	}
+
+	versions[schema.Version] = schema
+
+	return nil
+}
+
+// Check returns an error unless the schema of envelope is registered for
+// format and its event has the registered Go type.
+func (r *SchemaRegistry) Check(envelope Envelope, format Format) error {
+	r.mu.RLock()
+	schema, ok := r.schemas[envelope.Type][envelope.Version]
+	r.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("kafka: no schema registered for %s v%d, registered versions are %v",
+			envelope.Type, envelope.Version, r.Versions(envelope.Type))
+	}
+
+	if schema.Format != format {
+		return fmt.Errorf("kafka: %s v%d is registered as %s, not %s", envelope.Type, envelope.Version, schema.Format, format)
+	}
+
+	if got, want := reflect.TypeOf(envelope.Data), reflect.TypeOf(schema.Prototype); got != want {
+		return fmt.Errorf("kafka: %s v%d is registered for %s, not %s", envelope.Type, envelope.Version, want, got)
+	}
+
+	return nil
+}
+
+// Versions lists the registered versions of eventType, sorted.
+func (r *SchemaRegistry) Versions(eventType string) []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions := make([]int, 0, len(r.schemas[eventType]))
+	for version := range r.schemas[eventType] {
+		versions = append(versions, version)
+	}
+
+	sort.Ints(versions)
+
+	return versions
+}
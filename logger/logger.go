@@ -3,9 +3,10 @@ package logger
 import (
 	"context"
 	"encoding/json"
-	"os"
 	"sync"
+	"sync/atomic"
 
+	"restapi/config"
 	"restapi/util"
 
 	"github.com/google/uuid"
@@ -15,58 +16,76 @@ import (
 
 const (
 	TransactionIDKey = "TRANSACTION_ID"
+	CallerKey        = "CALLER"
 )
 
 type Z = map[string]interface{}
 
 var (
-	once      sync.Once
-	singleton *zap.SugaredLogger
+	once sync.Once
+	// stderrOnce guards the fallback apart from once, so that Configure
+	// still replaces it
+	stderrOnce sync.Once
+	singleton  atomic.Pointer[zap.SugaredLogger]
+	// level can be changed while the logger is in use
+	level = zap.NewAtomicLevel()
 )
 
-// Init initializes a thread-safe singleton logger
-// This would be called from a main method when the application starts up
-// This function would ideally, take zap configuration, but is left out
-// in favor of simplicity using the example logger.
-func Init(
-	name string,
-	logLevel string,
-) {
+// Configure initializes the thread-safe singleton logger writing to
+// {Dir}/{name}.log. It is called from a main method when the application
+// starts up, later calls have no effect.
+func Configure(name string, settings config.Log) {
 	// once ensures the singleton is initialized only once
 	once.Do(func() {
 		if name == "" {
 			name = "goofy"
 		}
 
-		// by default, this sets the minimum logging level to info
-		cfg := zap.NewProductionConfig()
-		cfg.Level.SetLevel(parseLogLevel(logLevel))
-
-		logDir := os.Getenv("LOG_DIR")
+		logDir := settings.Dir
 
 		if logDir == "" {
 			logDir = "logs"
 		}
 
+		// make the logDir if it does not exist
 		util.MakeDir(logDir, 0755)
 
-		outputFile := logDir + "/" + name + ".log"
+		singleton.Store(build(settings.Level, logDir+"/"+name+".log"))
+	})
+}
 
-		// make the logDir if it does not exist
+// configureStderr is the fallback when something logs before Configure
+// ran, as tests do, so that they do not leave log files behind. A later
+// Configure replaces it.
+func configureStderr() {
+	stderrOnce.Do(func() {
+		singleton.CompareAndSwap(nil, build("", "stderr"))
+	})
+}
 
-		cfg.OutputPaths = []string{outputFile}
+func build(logLevel string, outputPath string) *zap.SugaredLogger {
+	// by default, this sets the minimum logging level to info
+	cfg := zap.NewProductionConfig()
+	cfg.Level = level
+	cfg.Level.SetLevel(parseLogLevel(logLevel))
 
-		cfg.EncoderConfig.TimeKey = "logTime"
-		cfg.EncoderConfig.EncodeTime = zapcore.RFC3339TimeEncoder
+	cfg.OutputPaths = []string{outputPath}
 
-		cfg.EncoderConfig.MessageKey = "message"
+	cfg.EncoderConfig.TimeKey = "logTime"
+	cfg.EncoderConfig.EncodeTime = zapcore.RFC3339TimeEncoder
 
-		cfg.EncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+	cfg.EncoderConfig.MessageKey = "message"
 
-		builtLogger, _ := cfg.Build(zap.AddCallerSkip(2), zap.AddStacktrace(zapcore.DebugLevel))
+	cfg.EncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
 
-		singleton = builtLogger.Sugar()
-	})
+	builtLogger, _ := cfg.Build(zap.AddCallerSkip(2), zap.AddStacktrace(zapcore.DebugLevel))
+
+	return builtLogger.Sugar()
+}
+
+// SetLevel changes the minimum level of the running logger.
+func SetLevel(logLevel string) {
+	level.SetLevel(parseLogLevel(logLevel))
 }
 
 func Info(ctx context.Context, msg string, data Z) {
@@ -82,8 +101,10 @@ func Error(ctx context.Context, msg string, data Z) {
 }
 
 func log(ctx context.Context, level zapcore.Level, message string, data Z) {
-	if singleton == nil {
-		Init("other", os.Getenv("LOG_LEVEL"))
+	logger := singleton.Load()
+	if logger == nil {
+		configureStderr()
+		logger = singleton.Load()
 	}
 
 	if ctx == nil {
@@ -102,22 +123,22 @@ func log(ctx context.Context, level zapcore.Level, message string, data Z) {
 	switch level {
 	case zapcore.ErrorLevel:
 		{
-			singleton.Errorw(message, modifiedArgs...)
+			logger.Errorw(message, modifiedArgs...)
 			break
 		}
 	case zapcore.WarnLevel:
 		{
-			singleton.Warnw(message, modifiedArgs...)
+			logger.Warnw(message, modifiedArgs...)
 			break
 		}
 	case zapcore.InfoLevel:
 		{
-			singleton.Infow(message, modifiedArgs...)
+			logger.Infow(message, modifiedArgs...)
 			break
 		}
 	case zapcore.DebugLevel:
 		{
-			singleton.Debugw(message, modifiedArgs...)
+			logger.Debugw(message, modifiedArgs...)
 			break
 		}
 	}
@@ -136,6 +157,10 @@ func ingestData(ctx context.Context, inputdata string) []interface{} {
 		data[TransactionIDKey] = newTransactionID()
 	}
 
+	if caller := ctx.Value(CallerKey); caller != nil {
+		data[CallerKey] = caller
+	}
+
 	// organize Data in key, value, key, value... in an array of interface
 	argsLen := len(data) * 2 // key + value
 	args := make([]interface{}, argsLen)
@@ -155,6 +180,27 @@ func newTransactionID() string {
 	return uuid.New().String()
 }
 
+// NewTransactionID returns a fresh id for requests that did not bring one.
+func NewTransactionID() string {
+	return newTransactionID()
+}
+
+// WithTransactionID returns a copy of ctx carrying the given transaction id.
+func WithTransactionID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, TransactionIDKey, id)
+}
+
+// TransactionID returns the transaction id stored in ctx, or "" if none.
+func TransactionID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	id, _ := ctx.Value(TransactionIDKey).(string)
+
+	return id
+}
+
 func parseLogLevel(logLevel string) zapcore.Level {
 	switch logLevel {
 	case "debug":
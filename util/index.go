@@ -19,11 +19,8 @@ import (
 	"math/rand"
 	"net/url"
 	"os"
-	"path"
-	"path/filepath"
 	"reflect"
 	"regexp"
-	"runtime"
 	"sort"
 	"strconv"
 	"strings"
@@ -31,6 +28,8 @@ import (
 	"time"
 	"unicode"
 
+	"restapi/config"
+
 	"github.com/joho/godotenv"
 	"github.com/microcosm-cc/bluemonday"
 	"go.uber.org/zap/zapcore"
@@ -564,11 +563,10 @@ func GetLogLevel() zapcore.Level {
 	return zapLogLevel
 }
 
+// LoadEnv loads the env file env, e.g. ".test.env", from the config
+// directory into the process environment.
 func LoadEnv(env string) {
-	_, b, _, _ := runtime.Caller(0)
-	basepath := filepath.Dir(b)
-	ap := path.Join(basepath, "../../", env)
-	if err := godotenv.Load(ap); err != nil {
+	if err := godotenv.Load(config.EnvFile(env)); err != nil {
 		log.Fatalf("%s", err)
 	}
 }
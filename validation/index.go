@@ -0,0 +1,192 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"restapi/exceptions"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// RuleAtLeastOne is reported by struct rules when none of a set of
+// optional fields is given.
+const RuleAtLeastOne = "at_least_one"
+
+// codePattern is what the "code" rule accepts: letters, digits and _ - .
+// not starting with punctuation.
+var codePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+var (
+	setup sync.Once
+
+	mu       sync.RWMutex
+	messages = map[string]string{
+		"required":      "is required",
+		"required_with": "is required with %s",
+		"min":           "must be at least %s long",
+		"max":           "must be at most %s long",
+		"len":           "must be %s long",
+		"gt":            "must be greater than %s",
+		"gte":           "must be at least %s",
+		"lt":            "must be less than %s",
+		"lte":           "must be at most %s",
+		"oneof":         "must be one of %s",
+		"email":         "must be an email address",
+		"code":          "must be letters, digits, '_', '-' or '.' and start with a letter or digit",
+		RuleAtLeastOne:  "at least one of %s is required",
+	}
+)
+
+// engine is the validator gin binds with, set up on first use.
+func engine() *validator.Validate {
+	validate := binding.Validator.Engine().(*validator.Validate)
+
+	setup.Do(func() {
+		// report fields by the names clients send
+		validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+
+			if name == "" {
+				return field.Name
+			}
+
+			return name
+		})
+
+		_ = validate.RegisterValidation("code", func(fl validator.FieldLevel) bool {
+			return codePattern.MatchString(fl.Field().String())
+		})
+	})
+
+	return validate
+}
+
+// RegisterRule adds the binding tag rule, message describes a violation
+// and may hold one %s for the parameter of the tag. Services register
+// their rules once, when they start.
+func RegisterRule(tag string, rule validator.Func, message string) error {
+	if err := engine().RegisterValidation(tag, rule); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	messages[tag] = message
+
+	return nil
+}
+
+// RegisterStructRule validates types as a whole, for rules that span
+// fields. rule reports violations with StructLevel.ReportError.
+func RegisterStructRule(rule validator.StructLevelFunc, types ...interface{}) {
+	engine().RegisterStructValidation(rule, types...)
+}
+
+// AtLeastOne is a struct rule helper, it reports RuleAtLeastOne on the
+// struct unless one of fields is set.
+func AtLeastOne(sl validator.StructLevel, fields ...string) {
+	current := sl.Current()
+
+	for _, field := range fields {
+		if value := current.FieldByName(field); value.IsValid() && !value.IsZero() {
+			return
+		}
+	}
+
+	names := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if structField, ok := current.Type().FieldByName(field); ok {
+			name, _, _ := strings.Cut(structField.Tag.Get("json"), ",")
+			if name == "" {
+				name = field
+			}
+
+			names = append(names, name)
+		}
+	}
+
+	sl.ReportError(nil, "", "", RuleAtLeastOne, strings.Join(names, ", "))
+}
+
+// BindJSON decodes the body of the request into obj and validates it,
+// returning an exceptions.Validation that lists every violation.
+func BindJSON(c *gin.Context, obj interface{}) error {
+	engine()
+
+	return Translate(c.ShouldBindJSON(obj))
+}
+
+// Translate turns the errors of decoding and validating a payload into an
+// exceptions.Validation, other errors are returned as they are.
+func Translate(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var violations validator.ValidationErrors
+	if errors.As(err, &violations) {
+		fields := make([]exceptions.FieldError, 0, len(violations))
+		for _, violation := range violations {
+			fields = append(fields, fieldError(violation))
+		}
+
+		return exceptions.NewValidation("invalid request body", fields...).Wrap(err)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return exceptions.NewValidation("invalid request body", exceptions.FieldError{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: "must be a " + typeErr.Type.String(),
+		}).Wrap(err)
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return exceptions.NewValidation("request body is not valid JSON").Wrap(err)
+	}
+
+	return err
+}
+
+func fieldError(violation validator.FieldError) exceptions.FieldError {
+	mu.RLock()
+	message, ok := messages[violation.Tag()]
+	mu.RUnlock()
+
+	if !ok {
+		message = "must satisfy " + violation.Tag()
+	}
+
+	if strings.Contains(message, "%s") {
+		message = fmt.Sprintf(message, violation.Param())
+	}
+
+	return exceptions.FieldError{
+		Field:   path(violation.Namespace()),
+		Rule:    violation.Tag(),
+		Message: message,
+	}
+}
+
+// path drops the name of the top level struct from namespace, e.g.
+// "transactionRequest.Items[0].Code" is "Items[0].Code".
+func path(namespace string) string {
+	_, field, _ := strings.Cut(namespace, ".")
+
+	return field
+}
@@ -0,0 +1,83 @@
+package validation
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"restapi/exceptions"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type item struct {
+	Code string `json:"code" binding:"required,code"`
+}
+
+type payload struct {
+	Name  *string `json:"name" binding:"omitempty,max=3"`
+	Count int     `json:"count" binding:"omitempty,gt=0"`
+	Items []item  `json:"items" binding:"dive"`
+}
+
+func bind(t *testing.T, body string) error {
+	t.Helper()
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	var p payload
+
+	return BindJSON(c, &p)
+}
+
+func TestBindJSON_ReportsEveryField(t *testing.T) {
+	RegisterStructRule(func(sl validator.StructLevel) {
+		AtLeastOne(sl, "Name", "Count")
+	}, payload{})
+
+	err := bind(t, `{"items": [{"code": "ok-1"}, {"code": "-bad"}, {}]}`)
+
+	var e *exceptions.Error
+	if !errors.As(err, &e) || e.Kind != exceptions.Validation {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+
+	want := map[string]string{
+		"items[1].code": "code",
+		"items[2].code": "required",
+		"":              RuleAtLeastOne,
+	}
+
+	for _, field := range e.Fields {
+		if rule, ok := want[field.Field]; !ok || rule != field.Rule {
+			t.Errorf("unexpected violation %+v", field)
+		}
+
+		delete(want, field.Field)
+
+		if field.Rule == RuleAtLeastOne && field.Message != "at least one of name, count is required" {
+			t.Errorf("message: %q", field.Message)
+		}
+	}
+
+	if len(want) > 0 {
+		t.Errorf("missing violations: %v", want)
+	}
+}
+
+func TestBindJSON_MalformedBody(t *testing.T) {
+	err := bind(t, `{"count": "many"}`)
+
+	var e *exceptions.Error
+	if !errors.As(err, &e) || len(e.Fields) != 1 || e.Fields[0].Field != "count" || e.Fields[0].Rule != "type" {
+		t.Errorf("type mismatch: %+v", e)
+	}
+
+	if err := bind(t, `{"count":`); !exceptions.Is(err, exceptions.Validation) {
+		t.Errorf("truncated body: %v", err)
+	}
+}